@@ -0,0 +1,99 @@
+// Package classify asks the configured LLM how likely a post is to break the
+// forum rules, per category, for the moderators to review.
+package classify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"microblog/backend/pkg/util"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Categories the LLM scores a post in
+const (
+	Toxicity   = "toxicity"
+	Harassment = "harassment"
+	Spam       = "spam"
+	OffTopic   = "off_topic"
+)
+
+// Categories are all the categories of a classification
+var Categories = []string{Toxicity, Harassment, Spam, OffTopic}
+
+// maxInput is how much of a post is sent to the model, in runes
+const maxInput = 8000
+
+// Timeout bounds a classification, the post is left unclassified after it
+var Timeout = 20 * time.Second
+
+const prompt = `You are a moderation classifier for a discussion forum.
+Rate the post below from 0 to 1 for each category, 1 meaning it certainly belongs to it:
+- toxicity: insults, profanity, hateful or demeaning language
+- harassment: attacks, threats or intimidation aimed at a person
+- spam: advertising, scams, link farming, repeated promotional text
+- off_topic: unrelated to the forum category "%s"
+Answer with JSON only, like {"toxicity":0.1,"harassment":0,"spam":0.9,"off_topic":0.2}.
+
+Post:
+"""
+%s
+"""`
+
+// Enabled tells whether new posts are classified, LLM_MODERATION=true
+func Enabled() bool {
+	return util.Getenv("LLM_MODERATION", false)
+}
+
+// Threshold is the confidence from which category flags a post,
+// LLM_MODERATION_THRESHOLD_<CATEGORY> or else LLM_MODERATION_THRESHOLD (0.8)
+func Threshold(category string) float64 {
+	return util.Getenv("LLM_MODERATION_THRESHOLD_"+strings.ToUpper(category), util.Getenv("LLM_MODERATION_THRESHOLD", 0.8))
+}
+
+// Classify scores text, posted in category, in every category of Categories
+func Classify(ctx context.Context, model llms.Model, category, text string) (map[string]float64, error) {
+	if runes := []rune(text); len(runes) > maxInput {
+		text = string(runes[:maxInput])
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	answer, err := llms.GenerateFromSinglePrompt(ctx, model, fmt.Sprintf(prompt, category, text), llms.WithTemperature(0))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(answer)
+}
+
+// Parse reads the scores out of a model answer, tolerating text around the
+// JSON object. Missing categories score 0, scores are clamped to [0, 1].
+func Parse(answer string) (map[string]float64, error) {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in the model answer %q", answer)
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("invalid model answer: %w", err)
+	}
+	scores := make(map[string]float64, len(Categories))
+	for _, category := range Categories {
+		scores[category] = min(max(raw[category], 0), 1)
+	}
+	return scores, nil
+}
+
+// Flagged lists the categories whose score reaches their Threshold
+func Flagged(scores map[string]float64) []string {
+	var flagged []string
+	for _, category := range Categories {
+		if scores[category] >= Threshold(category) {
+			flagged = append(flagged, category)
+		}
+	}
+	return flagged
+}
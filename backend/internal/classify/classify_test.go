@@ -0,0 +1,160 @@
+package classify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"microblog/backend/pkg/llmClient"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name:   "json",
+			answer: `{"toxicity":0.1,"harassment":0,"spam":0.9,"off_topic":0.2}`,
+			want:   map[string]float64{Toxicity: 0.1, Harassment: 0, Spam: 0.9, OffTopic: 0.2},
+		},
+		{
+			name:   "text around the json",
+			answer: "Sure, here are the scores:\n```json\n{\"toxicity\": 0.05, \"spam\": 0.95}\n```\nLet me know if you need more.",
+			want:   map[string]float64{Toxicity: 0.05, Harassment: 0, Spam: 0.95, OffTopic: 0},
+		},
+		{
+			name:   "clamped",
+			answer: `{"toxicity":1.7,"harassment":-0.3,"spam":0.5,"off_topic":1}`,
+			want:   map[string]float64{Toxicity: 1, Harassment: 0, Spam: 0.5, OffTopic: 1},
+		},
+		{
+			name:   "unknown categories ignored",
+			answer: `{"spam":0.4,"violence":0.9}`,
+			want:   map[string]float64{Toxicity: 0, Harassment: 0, Spam: 0.4, OffTopic: 0},
+		},
+		{name: "no json", answer: "I cannot rate this post.", wantErr: true},
+		{name: "invalid json", answer: `{"spam": "high"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.answer)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertScores(t, got, tt.want)
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(prompt string) (string, error)
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name: "valid answer",
+			respond: func(string) (string, error) {
+				return `{"toxicity":0,"harassment":0,"spam":0.92,"off_topic":0.1}`, nil
+			},
+			want: map[string]float64{Toxicity: 0, Harassment: 0, Spam: 0.92, OffTopic: 0.1},
+		},
+		{
+			name: "text around the json",
+			respond: func(string) (string, error) {
+				return `The post reads like an ad. {"toxicity":0.2,"harassment":0.1,"spam":0.85,"off_topic":0.3} Hope this helps.`, nil
+			},
+			want: map[string]float64{Toxicity: 0.2, Harassment: 0.1, Spam: 0.85, OffTopic: 0.3},
+		},
+		{
+			name: "unreachable model",
+			respond: func(string) (string, error) {
+				return "", errors.New("dial tcp 127.0.0.1:11434: connection refused")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompt string
+			model := llmClient.Fake{Respond: func(p string) (string, error) {
+				prompt = p
+				return tt.respond(p)
+			}}
+			got, err := Classify(context.Background(), model, "programming", "Buy cheap followers today")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Classify = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertScores(t, got, tt.want)
+			if !strings.Contains(prompt, `"programming"`) || !strings.Contains(prompt, "Buy cheap followers today") {
+				t.Errorf("prompt lacks the category or the post:\n%s", prompt)
+			}
+		})
+	}
+}
+
+func TestClassifyTruncatesInput(t *testing.T) {
+	var prompt string
+	model := llmClient.Fake{Respond: func(p string) (string, error) {
+		prompt = p
+		return `{}`, nil
+	}}
+	if _, err := Classify(context.Background(), model, "general", strings.Repeat("é", 2*maxInput)); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(prompt, "é"); n != maxInput {
+		t.Errorf("%d runes of the post sent, want %d", n, maxInput)
+	}
+}
+
+func TestFlagged(t *testing.T) {
+	t.Setenv("LLM_MODERATION_THRESHOLD", "0.8")
+	t.Setenv("LLM_MODERATION_THRESHOLD_OFF_TOPIC", "0.95")
+	tests := []struct {
+		name   string
+		scores map[string]float64
+		want   []string
+	}{
+		{"nothing", map[string]float64{Toxicity: 0.1, Spam: 0.79}, nil},
+		{"at the threshold", map[string]float64{Spam: 0.8}, []string{Spam}},
+		{"category threshold", map[string]float64{OffTopic: 0.9, Harassment: 0.99}, []string{Harassment}},
+		{"several", map[string]float64{Toxicity: 0.85, Spam: 1, OffTopic: 0.97}, []string{Toxicity, Spam, OffTopic}},
+		{"no scores", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Flagged(tt.scores); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Flagged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertScores(t *testing.T, got, want map[string]float64) {
+	t.Helper()
+	if len(got) != len(Categories) {
+		t.Errorf("%d scores, want one per category: %v", len(got), got)
+	}
+	for category, score := range want {
+		if got[category] != score {
+			t.Errorf("%s = %v, want %v", category, got[category], score)
+		}
+	}
+}
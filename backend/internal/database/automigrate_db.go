@@ -2,7 +2,11 @@ package database
 
 import (
 	"fmt"
+	"microblog/backend/internal/embedding"
 	"microblog/backend/internal/model"
+	"microblog/backend/internal/ranking"
+	"microblog/backend/internal/search"
+	"microblog/backend/pkg/audit"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -40,6 +44,13 @@ func AutoMigrateDB(db *gorm.DB) error {
 		Icon:  "bx bx-radio-circle",
 	})
 
+	db.FirstOrCreate(&model.UserRole{
+		ID:    4,
+		Title: "Moderator", // works the report queue
+		Name:  "moderator",
+		Icon:  "bx bx-shield-quarter",
+	})
+
 	// Isi ability rule untuk role default
 	var count int64
 	db.Model(&model.UserAbilityRule{}).Where("role_id IN ?", []int{1, 2}).Count(&count)
@@ -53,15 +64,55 @@ func AutoMigrateDB(db *gorm.DB) error {
 			return fmt.Errorf("failed creating default abilities: %w", err)
 		}
 	}
+	if err := migrateVotes(db); err != nil {
+		return fmt.Errorf("failed removing duplicate votes: %w", err)
+	}
 	// Auto migrate all tables
 	if err := db.AutoMigrate(
 		&model.Thread{},
 		&model.Comment{},
 		&model.ThreadVote{},
 		&model.CommentVote{},
+		&model.Category{},
+		&model.Tag{},
+		&model.Notification{},
+		&model.NotificationActor{},
+		&model.ThreadWatch{},
+		&model.Mention{},
+		&model.Revision{},
+		&model.Report{},
+		&model.ReportEntry{},
+		&model.BookmarkCollection{},
+		&model.Bookmark{},
+		&model.Follow{},
+		&model.ReputationEvent{},
+		&model.Classification{},
+		&model.ThreadSummary{},
+		&audit.LogActivity{},
 	); err != nil {
 		logrus.Fatalf("AutoMigrate failed: %v", err)
 	}
+	if err := migrateCategories(db); err != nil {
+		return fmt.Errorf("failed migrating categories: %w", err)
+	}
+	if err := migrateMarkdown(db); err != nil {
+		return fmt.Errorf("failed rendering markdown: %w", err)
+	}
+	if err := migrateReputation(db); err != nil {
+		return fmt.Errorf("failed backfilling reputation: %w", err)
+	}
+	// Full-text search index, backfilled for threads created before it existed
+	if err := search.Migrate(db); err != nil {
+		logrus.Errorf("Search index migrate failed: %v", err)
+	}
+	// Embedding vectors, IndexService backfills them
+	if err := embedding.Migrate(db); err != nil {
+		logrus.Errorf("Embedding migrate failed: %v", err)
+	}
+	// Ranking scores for threads created before the score columns existed
+	if err := ranking.Backfill(db); err != nil {
+		logrus.Errorf("Ranking backfill failed: %v", err)
+	}
 
 	// Seed dummy user if table is empty
 	var userCount int64
@@ -2,7 +2,13 @@ package database
 
 import (
 	"os"
+	"time"
 
+	"microblog/backend/internal/embedding"
+	"microblog/backend/internal/leaderboard"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/ranking"
+	"microblog/backend/internal/search"
 	"microblog/backend/pkg/util"
 
 	"github.com/sirupsen/logrus"
@@ -33,6 +39,16 @@ func Init() error {
 		if err := AutoMigrateDB(DB); err != nil {
 			logrus.Fatalf("Auto migrate database failed: %v", err)
 		}
+		// Deleted threads and comments leave the trash after model.TrashRetention
+		go model.PurgeService(DB, time.Hour)
+		// Leaderboards are served from kvstore, recompute them in the background
+		go leaderboard.RefreshService(DB, 10*time.Minute)
+		// Comment writes queue their thread's search document, rebuild them in batches
+		go search.ReindexService(DB, 2*time.Second)
+		// Threads and comments are embedded for semantic search in the background
+		go embedding.IndexService(DB, time.Minute)
+		// Rising scores decay with age, refresh them in the background
+		ranking.RefreshService(DB, 5*time.Minute)
 	}()
 	return nil
 }
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"strings"
+
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/util"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// defaultCategories mirror the choices offered by the thread composer
+var defaultCategories = []string{
+	"general",
+	"technology",
+	"programming",
+	"design",
+	"business",
+	"science",
+	"entertainment",
+	"sports",
+	"other",
+}
+
+// migrateCategories seeds the default categories into an empty table and
+// turns every free-form Thread.Category string into the slug of a category,
+// so "General" and "general " end up in the same bucket. Categories that
+// moderators deleted or renamed stay that way across restarts: the defaults
+// are only seeded once, and threads already holding a slug are left alone.
+func migrateCategories(db *gorm.DB) error {
+	title := cases.Title(language.English)
+	var count int64
+	if err := db.Model(&model.Category{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		for i, slug := range defaultCategories {
+			if err := db.Create(&model.Category{
+				Slug:     slug,
+				Name:     title.String(slug),
+				Position: i,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var names []string
+	if err := db.Model(&model.Thread{}).Distinct("category").Pluck("category", &names).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		slug := util.Slugify(name)
+		if slug == "" {
+			slug = "general"
+		}
+		if slug == name {
+			continue
+		}
+		if err := db.Where("slug = ?", slug).FirstOrCreate(&model.Category{
+			Slug:     slug,
+			Name:     title.String(strings.TrimSpace(name)),
+			Position: len(defaultCategories),
+		}).Error; err != nil {
+			return err
+		}
+		// UpdateColumn skips hooks, the search document does not hold the category
+		if err := db.Model(&model.Thread{}).Where("category = ?", name).UpdateColumn("category", slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"microblog/backend/pkg/markdown"
+
+	"gorm.io/gorm"
+)
+
+// migrateMarkdown renders body_html/content_html for threads and comments
+// written before the rendered columns existed
+func migrateMarkdown(db *gorm.DB) error {
+	if err := renderColumn(db, "threads", "body", "body_html"); err != nil {
+		return err
+	}
+	return renderColumn(db, "comments", "content", "content_html")
+}
+
+func renderColumn(db *gorm.DB, table, source, target string) error {
+	type row struct {
+		ID     string
+		Source string
+	}
+	var rows []row
+	return db.Table(table).
+		Select("id, "+source+" AS source").
+		Where("("+target+" IS NULL OR "+target+" = '') AND "+source+" <> ''").
+		FindInBatches(&rows, 200, func(batch *gorm.DB, _ int) error {
+			for _, r := range rows {
+				// Table updates skip the model hooks, nothing else depends on the HTML
+				if err := db.Table(table).Where("id = ?", r.ID).
+					UpdateColumn(target, string(markdown.Render(r.Source))).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
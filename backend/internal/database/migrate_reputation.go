@@ -0,0 +1,101 @@
+package database
+
+import (
+	"time"
+
+	"microblog/backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// migrateReputation fills an empty reputation ledger from the votes and
+// comments written before it existed and sums it up into users.karma
+func migrateReputation(db *gorm.DB) error {
+	var events int64
+	if err := db.Model(&model.ReputationEvent{}).Count(&events).Error; err != nil || events > 0 {
+		return err
+	}
+
+	sources := []struct {
+		sourceType string
+		query      *gorm.DB
+		up, down   string
+	}{
+		{
+			sourceType: model.RevisionSourceThread,
+			query: db.Table("thread_votes").
+				Select("threads.user_id, thread_votes.user_id AS actor_id, thread_votes.vote_type AS reason, threads.id AS source_id, threads.id AS thread_id, thread_votes.created_at").
+				Joins("JOIN threads ON threads.id = thread_votes.thread_id").
+				Where("thread_votes.vote_type IN ? AND threads.user_id <> thread_votes.user_id", []string{"up", "down"}),
+			up:   model.RepThreadUpvoted,
+			down: model.RepThreadDownvoted,
+		},
+		{
+			sourceType: model.RevisionSourceComment,
+			query: db.Table("comment_votes").
+				Select("comments.user_id, comment_votes.user_id AS actor_id, comment_votes.vote_type AS reason, comments.id AS source_id, comments.thread_id, comment_votes.created_at").
+				Joins("JOIN comments ON comments.id = comment_votes.comment_id").
+				Where("comment_votes.vote_type IN ? AND comments.user_id <> comment_votes.user_id", []string{"up", "down"}),
+			up:   model.RepCommentUpvoted,
+			down: model.RepCommentDownvoted,
+		},
+		{
+			sourceType: model.RevisionSourceComment,
+			query: db.Table("comments").
+				Select("user_id, user_id AS actor_id, ? AS reason, id AS source_id, thread_id, created_at", model.RepCommentPosted).
+				Where("deleted_at IS NULL AND moderation = ?", model.ModerationVisible),
+		},
+	}
+
+	type row struct {
+		UserID    string
+		ActorID   string
+		Reason    string
+		SourceID  string
+		ThreadID  string
+		CreatedAt time.Time
+	}
+	for _, source := range sources {
+		rows, err := source.query.Rows()
+		if err != nil {
+			return err
+		}
+		batch := make([]model.ReputationEvent, 0, 500)
+		for rows.Next() {
+			var r row
+			if err := db.ScanRows(rows, &r); err != nil {
+				rows.Close()
+				return err
+			}
+			switch r.Reason {
+			case "up":
+				r.Reason = source.up
+			case "down":
+				r.Reason = source.down
+			}
+			batch = append(batch, model.ReputationEvent{
+				UserID:     r.UserID,
+				ActorID:    r.ActorID,
+				Reason:     r.Reason,
+				Points:     model.ReputationPoints[r.Reason],
+				SourceType: source.sourceType,
+				SourceID:   r.SourceID,
+				ThreadID:   r.ThreadID,
+				CreatedAt:  r.CreatedAt,
+			})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := db.CreateInBatches(&batch, 500).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.Model(&model.User{}).Where("1 = 1").UpdateColumn("karma",
+		db.Model(&model.ReputationEvent{}).Select("COALESCE(SUM(points), 0)").Where("reputation_events.user_id = users.id"),
+	).Error
+}
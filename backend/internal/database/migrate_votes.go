@@ -0,0 +1,46 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// migrateVotes drops the duplicate votes racing requests left behind before
+// votes were unique per (target, user), keeping one vote per user, and
+// recounts the totals of what they voted on. It runs before AutoMigrate
+// adds the unique indexes.
+func migrateVotes(db *gorm.DB) error {
+	for _, votes := range []struct{ table, column, target string }{
+		{"thread_votes", "thread_id", "threads"},
+		{"comment_votes", "comment_id", "comments"},
+	} {
+		if !db.Migrator().HasTable(votes.table) {
+			continue
+		}
+		// The derived table keeps MySQL from rejecting a self-referencing DELETE
+		res := db.Exec(`
+			DELETE FROM ` + votes.table + `
+			WHERE id NOT IN (
+				SELECT id FROM (SELECT MAX(id) AS id FROM ` + votes.table + ` GROUP BY ` + votes.column + `, user_id) AS keep
+			)`)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		if err := db.Exec(`
+			UPDATE ` + votes.target + `
+			SET
+			total_up_votes = (
+				SELECT COUNT(*) FROM ` + votes.table + `
+				WHERE ` + votes.table + `.` + votes.column + ` = ` + votes.target + `.id AND vote_type = 'up'
+			),
+			total_down_votes = (
+				SELECT COUNT(*) FROM ` + votes.table + `
+				WHERE ` + votes.table + `.` + votes.column + ` = ` + votes.target + `.id AND vote_type = 'down'
+			)`).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Package embedding stores an embedding vector per thread and comment, from
+// the embedder of llmClient, and finds the nearest ones by cosine
+// similarity: the semantic search and the related threads. IndexService
+// computes them in the background.
+package embedding
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"microblog/backend/pkg/kvstore"
+	"microblog/backend/pkg/llmClient"
+	"microblog/backend/pkg/util"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// TableName is the table of the vectors, one row per thread and comment
+const TableName = "embeddings"
+
+// Source types of a vector
+const (
+	SourceThread  = "threads"
+	SourceComment = "comments"
+)
+
+// Row is the stored vector of a thread or comment
+type Row struct {
+	SourceType string    `gorm:"column:source_type;primaryKey;size:20"`
+	SourceID   string    `gorm:"column:source_id;primaryKey;size:36"`
+	ThreadID   string    `gorm:"column:thread_id;size:36;index"`
+	Model      string    `gorm:"column:model;size:100"` // vectors of different models are not compared
+	Hash       string    `gorm:"column:hash;size:64"`   // of the embedded text, unchanged text is not embedded again
+	Vector     []byte    `gorm:"column:vector"`         // little endian float32s, see Encode
+	UpdatedAt  time.Time `gorm:"column:updated_at"`
+}
+
+func (Row) TableName() string {
+	return TableName
+}
+
+// Match is a thread or comment near the searched vector
+type Match struct {
+	SourceID   string  `json:"source_id"`
+	ThreadID   string  `json:"thread_id"`
+	Similarity float64 `json:"similarity"` // cosine, 1 is the same direction
+}
+
+// Backend finds the nearest vectors
+type Backend interface {
+	// Put stores the vector of a row that was just saved, for backends
+	// keeping their own copy
+	Put(db *gorm.DB, row Row, vector []float32) error
+	// Nearest returns the limit rows of sourceType and model most similar to
+	// vector, leaving out the source ids of exclude
+	Nearest(db *gorm.DB, model, sourceType string, vector []float32, limit int, exclude []string) ([]Match, error)
+}
+
+// pgvector is set once Migrate enabled the vector extension on Postgres
+var pgvector atomic.Bool
+
+// For picks pgvector on Postgres when the extension is available, the
+// scan in Go otherwise
+func For(db *gorm.DB) Backend {
+	if db.Dialector.Name() == "postgres" && pgvector.Load() {
+		return pgvectorBackend{}
+	}
+	return scanBackend{}
+}
+
+// Migrate creates the vector table, and on Postgres the pgvector column
+// when the extension can be created. The vectors themselves are backfilled
+// by IndexService.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Row{}); err != nil {
+		return err
+	}
+	if db.Dialector.Name() == "postgres" {
+		if err := migratePgvector(db); err != nil {
+			logrus.Warnf("embedding: pgvector unavailable, similarity is computed in Go: %v", err)
+		} else {
+			pgvector.Store(true)
+		}
+	}
+	return nil
+}
+
+// Encode packs a vector for Row.Vector
+func Encode(vector []float32) []byte {
+	b := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+// Decode unpacks Row.Vector
+func Decode(b []byte) []float32 {
+	vector := make([]float32, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return vector
+}
+
+// Cosine is the cosine similarity of two vectors of the same length
+func Cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// MinSimilarity is the similarity below which matches are left out,
+// EMBEDDING_MIN_SIMILARITY (0.3)
+func MinSimilarity() float64 {
+	return util.Getenv("EMBEDDING_MIN_SIMILARITY", 0.3)
+}
+
+// similar keeps the matches of at least MinSimilarity, matches are sorted
+func similar(matches []Match) []Match {
+	threshold := MinSimilarity()
+	for i, m := range matches {
+		if m.Similarity < threshold {
+			return matches[:i]
+		}
+	}
+	return matches
+}
+
+// queryTimeout bounds embedding a search query
+const queryTimeout = 10 * time.Second
+
+// Search embeds q and returns the limit threads or comments nearest to it
+func Search(ctx context.Context, db *gorm.DB, q, sourceType string, limit int) ([]Match, error) {
+	embedder, model, err := llmClient.Embedder()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	vector, err := embedder.EmbedQuery(ctx, truncate(q))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := For(db).Nearest(db, model, sourceType, vector, limit, nil)
+	return similar(matches), err
+}
+
+// relatedTTL is how long the related threads of a thread are cached
+const relatedTTL = 15 * time.Minute
+
+// Related returns the limit threads nearest to a thread, from its stored
+// vector, cached in kvstore. A thread without a vector yet has none.
+func Related(db *gorm.DB, threadID string, limit int) ([]Match, error) {
+	key := "embedding:related:" + threadID + ":" + strconv.Itoa(limit)
+	if cached, err := kvstore.GetKey(key); err == nil {
+		var matches []Match
+		if json.Unmarshal([]byte(cached), &matches) == nil {
+			return matches, nil
+		}
+	}
+
+	_, model, err := llmClient.Embedder()
+	if err != nil {
+		return nil, err
+	}
+	var row Row
+	found := db.Where("source_type = ? AND source_id = ? AND model = ?", SourceThread, threadID, model).Limit(1).Find(&row)
+	if found.Error != nil || found.RowsAffected == 0 {
+		return []Match{}, found.Error
+	}
+	matches, err := For(db).Nearest(db, model, SourceThread, Decode(row.Vector), limit, []string{threadID})
+	if err != nil {
+		return nil, err
+	}
+	matches = similar(matches)
+	if b, err := json.Marshal(matches); err == nil {
+		kvstore.SetKey(key, string(b), relatedTTL)
+	}
+	return matches, nil
+}
@@ -0,0 +1,305 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const fakeModel = "fake/hash-256"
+
+// countingEmbedder is a FakeEmbedder counting the documents it embeds
+type countingEmbedder struct {
+	llmClient.FakeEmbedder
+	documents *int
+}
+
+func (e countingEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	*e.documents += len(texts)
+	return e.FakeEmbedder.EmbedDocuments(ctx, texts)
+}
+
+// testDB is an empty database with the columns of threads and comments the
+// index reads
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE threads (id TEXT PRIMARY KEY, title TEXT, body TEXT, updated_at DATETIME, deleted_at DATETIME)",
+		"CREATE TABLE comments (id TEXT PRIMARY KEY, thread_id TEXT, content TEXT, updated_at DATETIME, deleted_at DATETIME)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func addThread(t *testing.T, db *gorm.DB, id, title, body string) {
+	t.Helper()
+	if err := db.Exec("INSERT INTO threads (id, title, body, updated_at) VALUES (?, ?, ?, ?)", id, title, body, time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addComment(t *testing.T, db *gorm.DB, id, threadID, content string) {
+	t.Helper()
+	if err := db.Exec("INSERT INTO comments (id, thread_id, content, updated_at) VALUES (?, ?, ?, ?)", id, threadID, content, time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func vectors(t *testing.T, db *gorm.DB, sourceType string) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Table(TableName).Where("source_type = ?", sourceType).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEncodeDecode(t *testing.T) {
+	vector := []float32{0, 1, -1, 0.5, -0.25, math.MaxFloat32, math.SmallestNonzeroFloat32}
+	got := Decode(Encode(vector))
+	if len(got) != len(vector) {
+		t.Fatalf("decoded %d values, want %d", len(got), len(vector))
+	}
+	for i := range vector {
+		if got[i] != vector[i] {
+			t.Errorf("value %d = %v, want %v", i, got[i], vector[i])
+		}
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"same direction", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Cosine = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	query := []float32{1, 1, 0}
+	near, far := []float32{1, 0.8, 0.1}, []float32{0.1, 0.2, 1}
+	if Cosine(query, near) <= Cosine(query, far) {
+		t.Errorf("Cosine ranks %v (%v) below %v (%v)", near, Cosine(query, near), far, Cosine(query, far))
+	}
+}
+
+func TestScanNearest(t *testing.T) {
+	db := testDB(t)
+	rows := []Row{
+		{SourceType: SourceThread, SourceID: "far", Vector: Encode([]float32{0, 1})},
+		{SourceType: SourceThread, SourceID: "best", Vector: Encode([]float32{1, 0})},
+		{SourceType: SourceThread, SourceID: "third", Vector: Encode([]float32{1, 1})},
+		{SourceType: SourceThread, SourceID: "second", Vector: Encode([]float32{1, 0.2})},
+		{SourceType: SourceThread, SourceID: "opposite", Vector: Encode([]float32{-1, 0})},
+		{SourceType: SourceThread, SourceID: "other-dimensions", Vector: Encode([]float32{1, 0, 0})},
+		{SourceType: SourceComment, SourceID: "comment", Vector: Encode([]float32{1, 0})},
+	}
+	for i := range rows {
+		rows[i].ThreadID = rows[i].SourceID
+		rows[i].Model = fakeModel
+		if err := db.Create(&rows[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&Row{SourceType: SourceThread, SourceID: "other-model", Model: "other", Vector: Encode([]float32{1, 0})}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		exclude []string
+		want    []string
+	}{
+		{"top 3", 3, nil, []string{"best", "second", "third"}},
+		{"all", 10, nil, []string{"best", "second", "third", "far", "opposite"}},
+		{"excluded", 2, []string{"best"}, []string{"second", "third"}},
+		{"top 1", 1, nil, []string{"best"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := scanBackend{}.Nearest(db, fakeModel, SourceThread, []float32{1, 0}, tt.limit, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.SourceID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Nearest = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Nearest = %v, want %v", got, tt.want)
+				}
+			}
+			for i := 1; i < len(matches); i++ {
+				if matches[i].Similarity > matches[i-1].Similarity {
+					t.Errorf("match %d is more similar than match %d", i, i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexPending(t *testing.T) {
+	db := testDB(t)
+	documents := 0
+	llmClient.SetEmbedder(countingEmbedder{documents: &documents}, fakeModel)
+
+	addThread(t, db, "t1", "Connecting Go to Postgres", "gorm says connection refused")
+	addThread(t, db, "t2", "Best racing game", "looking for racing games on console")
+	addComment(t, db, "c1", "t1", "check the port of the database")
+
+	n, err := IndexPending(db, batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || documents != 3 {
+		t.Fatalf("first run looked at %d posts and embedded %d, want 3 and 3", n, documents)
+	}
+	if vectors(t, db, SourceThread) != 2 || vectors(t, db, SourceComment) != 1 {
+		t.Fatal("not every post has a vector")
+	}
+
+	if n, err = IndexPending(db, batchSize); err != nil || n != 0 {
+		t.Fatalf("second run looked at %d posts (%v), want none", n, err)
+	}
+
+	// A save that leaves the text alone, like a vote, keeps the vector
+	time.Sleep(time.Millisecond)
+	db.Exec("UPDATE threads SET updated_at = ? WHERE id = ?", time.Now(), "t2")
+	if n, err = IndexPending(db, batchSize); err != nil || n != 1 {
+		t.Fatalf("run after a save looked at %d posts (%v), want 1", n, err)
+	}
+	if documents != 3 {
+		t.Errorf("unchanged text embedded again, %d documents embedded", documents)
+	}
+	if n, _ = IndexPending(db, batchSize); n != 0 {
+		t.Errorf("the saved post is still pending")
+	}
+
+	// An edit does embed the post again
+	time.Sleep(time.Millisecond)
+	db.Exec("UPDATE comments SET content = ?, updated_at = ? WHERE id = ?", "check the port and the password", time.Now(), "c1")
+	if _, err = IndexPending(db, batchSize); err != nil {
+		t.Fatal(err)
+	}
+	if documents != 4 {
+		t.Errorf("edited post not embedded again, %d documents embedded", documents)
+	}
+
+	// Vectors of another model are replaced
+	llmClient.SetEmbedder(countingEmbedder{documents: &documents}, "fake/other")
+	if n, err = IndexPending(db, batchSize); err != nil || n != 3 {
+		t.Fatalf("model change looked at %d posts (%v), want 3", n, err)
+	}
+	if documents != 7 {
+		t.Errorf("model change embedded %d documents, want 3", documents-4)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := testDB(t)
+	llmClient.SetEmbedder(llmClient.FakeEmbedder{}, fakeModel)
+	addThread(t, db, "t1", "Purged thread", "gone for good")
+	addThread(t, db, "t2", "Trashed thread", "still in the trash")
+	addComment(t, db, "c1", "t2", "purged comment")
+	addComment(t, db, "c2", "t2", "live comment")
+	if _, err := IndexPending(db, batchSize); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Exec("DELETE FROM threads WHERE id = ?", "t1")
+	db.Exec("UPDATE threads SET deleted_at = ? WHERE id = ?", time.Now(), "t2")
+	db.Exec("DELETE FROM comments WHERE id = ?", "c1")
+	if err := Prune(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var left []string
+	db.Table(TableName).Order("source_id").Pluck("source_id", &left)
+	if len(left) != 2 || left[0] != "c2" || left[1] != "t2" {
+		t.Errorf("vectors left for %v, want [c2 t2]: soft deleted posts keep theirs until purged", left)
+	}
+}
+
+func TestSearchAndRelated(t *testing.T) {
+	db := testDB(t)
+	t.Setenv("EMBEDDING_MIN_SIMILARITY", "0.3")
+	llmClient.SetEmbedder(llmClient.FakeEmbedder{}, fakeModel)
+	addThread(t, db, "postgres", "How do I connect golang to a postgres database", "gorm postgres connection refused")
+	addThread(t, db, "postgres-again", "Postgres connection from a golang service fails", "database connection refused with gorm")
+	addThread(t, db, "racing", "Best racing game on console", "looking for racing games")
+	addComment(t, db, "racing-comment", "racing", "try the new racing game on the console")
+	if _, err := IndexPending(db, batchSize); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := Search(context.Background(), db, "golang postgres connection", SourceThread, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].Similarity < matches[1].Similarity {
+		t.Fatalf("Search = %+v, want the two postgres threads, most similar first", matches)
+	}
+	for _, m := range matches {
+		if m.ThreadID == "racing" {
+			t.Errorf("unrelated thread %s matched with %v", m.SourceID, m.Similarity)
+		}
+	}
+
+	matches, err = Search(context.Background(), db, "racing console", SourceComment, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].SourceID != "racing-comment" || matches[0].ThreadID != "racing" {
+		t.Errorf("comment Search = %+v, want racing-comment", matches)
+	}
+
+	related, err := Related(db, "postgres", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(related) != 1 || related[0].SourceID != "postgres-again" {
+		t.Errorf("Related = %+v, want only postgres-again", related)
+	}
+
+	related, err = Related(db, "not-indexed", 3)
+	if err != nil || len(related) != 0 {
+		t.Errorf("Related of a thread without a vector = %+v, %v, want none", related, err)
+	}
+}
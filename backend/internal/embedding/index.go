@@ -0,0 +1,188 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is how many posts are embedded per call to the embedder
+const batchSize = 32
+
+// maxInput is how much of a post is embedded, in runes
+const maxInput = 4000
+
+// indexTimeout bounds embedding one batch
+const indexTimeout = time.Minute
+
+var wake = make(chan struct{}, 1)
+
+// Wake has IndexService look for new posts now instead of at its next tick,
+// without waiting
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// IndexService embeds the threads and comments that are new, edited, from
+// before embeddings or of another model, run it in a goroutine. It looks
+// every interval and when woken by Wake, and stops when no embedder is
+// configured.
+func IndexService(db *gorm.DB, interval time.Duration) {
+	if _, _, err := llmClient.Embedder(); err != nil {
+		if !errors.Is(err, llmClient.ErrNoProvider) {
+			logrus.Errorf("embedding: %v", err)
+		}
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		indexed := 0
+		for {
+			n, err := IndexPending(db, batchSize)
+			indexed += n
+			if err != nil {
+				logrus.Errorf("embedding: index: %v", err)
+				break
+			}
+			if n < batchSize {
+				break
+			}
+		}
+		if indexed > 0 {
+			logrus.Infof("embedding: indexed %d posts", indexed)
+		}
+		if err := Prune(db); err != nil {
+			logrus.Errorf("embedding: prune: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+			// let the transaction that woke us commit
+			time.Sleep(2 * time.Second)
+		}
+	}
+}
+
+// pending is a post whose vector is missing or may be out of date
+type pending struct {
+	SourceType string
+	SourceID   string
+	ThreadID   string
+	Title      string
+	Body       string
+	Hash       *string // of the stored vector, nil without one
+	Model      *string
+}
+
+// IndexPending embeds up to limit posts that need it and returns how many
+// it looked at, threads first
+func IndexPending(db *gorm.DB, limit int) (int, error) {
+	embedder, model, err := llmClient.Embedder()
+	if err != nil {
+		return 0, err
+	}
+
+	var posts []pending
+	if err := db.Table("threads").
+		Select("'"+SourceThread+"' AS source_type, threads.id AS source_id, threads.id AS thread_id, threads.title, threads.body, e.hash, e.model").
+		Joins("LEFT JOIN "+TableName+" e ON e.source_type = ? AND e.source_id = threads.id", SourceThread).
+		Where("threads.deleted_at IS NULL").
+		Where("e.source_id IS NULL OR e.model <> ? OR e.updated_at < threads.updated_at", model).
+		Limit(limit).
+		Scan(&posts).Error; err != nil {
+		return 0, err
+	}
+	if len(posts) < limit {
+		var comments []pending
+		if err := db.Table("comments").
+			Select("'"+SourceComment+"' AS source_type, comments.id AS source_id, comments.thread_id, '' AS title, comments.content AS body, e.hash, e.model").
+			Joins("LEFT JOIN "+TableName+" e ON e.source_type = ? AND e.source_id = comments.id", SourceComment).
+			Where("comments.deleted_at IS NULL").
+			Where("e.source_id IS NULL OR e.model <> ? OR e.updated_at < comments.updated_at", model).
+			Limit(limit - len(posts)).
+			Scan(&comments).Error; err != nil {
+			return 0, err
+		}
+		posts = append(posts, comments...)
+	}
+	if len(posts) == 0 {
+		return 0, nil
+	}
+
+	// Posts whose text did not change, like after a vote, keep their vector
+	now := time.Now()
+	var texts []string
+	var rows []Row
+	for _, p := range posts {
+		text := truncate(strings.TrimSpace(p.Title + "\n\n" + p.Body))
+		sum := sha256.Sum256([]byte(text))
+		hash := hex.EncodeToString(sum[:])
+		if p.Hash != nil && *p.Hash == hash && p.Model != nil && *p.Model == model {
+			if err := db.Model(&Row{}).Where("source_type = ? AND source_id = ?", p.SourceType, p.SourceID).
+				UpdateColumn("updated_at", now).Error; err != nil {
+				return 0, err
+			}
+			continue
+		}
+		texts = append(texts, text)
+		rows = append(rows, Row{SourceType: p.SourceType, SourceID: p.SourceID, ThreadID: p.ThreadID, Model: model, Hash: hash, UpdatedAt: now})
+	}
+	if len(rows) == 0 {
+		return len(posts), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+	vectors, err := embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != len(rows) {
+		return 0, errors.New("the embedder returned a different number of vectors")
+	}
+	backend := For(db)
+	for i := range rows {
+		rows[i].Vector = Encode(vectors[i])
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source_type"}, {Name: "source_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"thread_id", "model", "hash", "vector", "updated_at"}),
+		}).Create(&rows[i]).Error; err != nil {
+			return 0, err
+		}
+		if err := backend.Put(db, rows[i], vectors[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(posts), nil
+}
+
+// Prune removes the vectors of posts deleted for good
+func Prune(db *gorm.DB) error {
+	for _, source := range []string{SourceThread, SourceComment} {
+		if err := db.Exec("DELETE FROM "+TableName+" WHERE source_type = ? AND NOT EXISTS (SELECT 1 FROM "+source+" WHERE "+source+".id = "+TableName+".source_id)", source).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func truncate(text string) string {
+	if runes := []rune(text); len(runes) > maxInput {
+		return string(runes[:maxInput])
+	}
+	return text
+}
@@ -0,0 +1,74 @@
+package embedding
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// pgvectorBackend keeps a copy of each vector in a pgvector column and lets
+// Postgres rank them with the cosine distance operator
+type pgvectorBackend struct{}
+
+// migratePgvector enables the extension and adds the vector column, filled
+// from the stored vectors when it is new. The column has no dimensions, so
+// models of any size fit, which leaves it without an ANN index.
+func migratePgvector(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&Row{}, "embedding") {
+		return nil
+	}
+	if err := db.Exec("ALTER TABLE " + TableName + " ADD COLUMN embedding vector").Error; err != nil {
+		return err
+	}
+	rows, err := db.Model(&Row{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row Row
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := (pgvectorBackend{}).Put(db, row, Decode(row.Vector)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (pgvectorBackend) Put(db *gorm.DB, row Row, vector []float32) error {
+	return db.Exec("UPDATE "+TableName+" SET embedding = ?::vector WHERE source_type = ? AND source_id = ?",
+		literal(vector), row.SourceType, row.SourceID).Error
+}
+
+func (pgvectorBackend) Nearest(db *gorm.DB, model, sourceType string, vector []float32, limit int, exclude []string) ([]Match, error) {
+	v := literal(vector)
+	query := db.Table(TableName).
+		Select("source_id, thread_id, 1 - (embedding <=> ?::vector) AS similarity", v).
+		Where("source_type = ? AND model = ? AND embedding IS NOT NULL AND vector_dims(embedding) = ?", sourceType, model, len(vector))
+	if len(exclude) > 0 {
+		query = query.Where("source_id NOT IN ?", exclude)
+	}
+	var matches []Match
+	err := query.Order(gorm.Expr("embedding <=> ?::vector", v)).Limit(limit).Scan(&matches).Error
+	return matches, err
+}
+
+// literal is the text form of a pgvector value, [1,2,3]
+func literal(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
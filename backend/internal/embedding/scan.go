@@ -0,0 +1,50 @@
+package embedding
+
+import (
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// scanBackend reads every vector of the source type and ranks them in Go,
+// fine for the tens of thousands of posts of a forum on SQLite or MySQL
+type scanBackend struct{}
+
+func (scanBackend) Put(db *gorm.DB, row Row, vector []float32) error {
+	return nil
+}
+
+func (scanBackend) Nearest(db *gorm.DB, model, sourceType string, vector []float32, limit int, exclude []string) ([]Match, error) {
+	rows, err := db.Table(TableName).
+		Select("source_id, thread_id, vector").
+		Where("source_type = ? AND model = ?", sourceType, model).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// best holds the limit most similar so far, most similar first
+	best := make([]Match, 0, limit+1)
+	for rows.Next() {
+		var m Match
+		var b []byte
+		if err := rows.Scan(&m.SourceID, &m.ThreadID, &b); err != nil {
+			return nil, err
+		}
+		if len(b) != 4*len(vector) || slices.Contains(exclude, m.SourceID) {
+			continue
+		}
+		m.Similarity = Cosine(vector, Decode(b))
+		if len(best) == limit && m.Similarity <= best[limit-1].Similarity {
+			continue
+		}
+		i := sort.Search(len(best), func(i int) bool { return best[i].Similarity < m.Similarity })
+		best = slices.Insert(best, i, m)
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+	return best, rows.Err()
+}
@@ -15,7 +15,7 @@ func ApplyQueryFilters(db *gorm.DB, query map[string][]string, schema map[string
 		field, op := parseKey(rawKey)
 
 		// Skip non-filter query params (pagination, sorting, etc.)
-		if util.Contains([]string{"draw", "start", "length", "sort", "fields", "schema"}, field) {
+		if util.Contains(ReservedParams, field) {
 			continue
 		}
 
@@ -0,0 +1,252 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SortKey is one column of a keyset ordering
+type SortKey struct {
+	Key    string    // json key, part of the cursor signature
+	Column string    // db column used in ORDER BY and WHERE
+	Type   FieldType // decides how cursor values are decoded
+	Desc   bool
+}
+
+// cursorPayload is what an opaque cursor decodes to: the sort it was built
+// for and the sort values of the last row of the previous page
+type cursorPayload struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+// KeysetSort parses a sort param (same syntax as ApplySorting) into sort keys
+// ending with "id", so every row has a unique position
+func KeysetSort(sort string, schema map[string]FieldSchema) ([]SortKey, error) {
+	var keys []SortKey
+	hasID := false
+	for _, s := range strings.Split(sort, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		desc := strings.HasPrefix(s, "-")
+		s = strings.TrimPrefix(s, "-")
+
+		f, ok := schema[s]
+		if !ok {
+			return nil, &FilterError{
+				Code:    ErrInvalidField,
+				Message: fmt.Sprintf("Sort field '%s' does not exist", s),
+				Field:   s,
+			}
+		}
+		if !f.Sortable && f.JSONKey != "id" {
+			return nil, &FilterError{
+				Code:    ErrFieldNotSortable,
+				Message: fmt.Sprintf("Field '%s' is not sortable", s),
+				Field:   s,
+			}
+		}
+		if f.JSONKey == "id" {
+			hasID = true
+		}
+		keys = append(keys, SortKey{Key: f.JSONKey, Column: f.DBColumn, Type: f.Type, Desc: desc})
+	}
+
+	if !hasID {
+		id, ok := schema["id"]
+		if !ok {
+			return nil, &FilterError{
+				Code:    ErrInvalidCursor,
+				Message: "Cursor pagination needs an 'id' field",
+			}
+		}
+		// Tie-break in the direction of the last key
+		desc := len(keys) > 0 && keys[len(keys)-1].Desc
+		keys = append(keys, SortKey{Key: id.JSONKey, Column: id.DBColumn, Type: id.Type, Desc: desc})
+	}
+	return keys, nil
+}
+
+// ApplyKeyset orders q by keys and, when cursor is not empty, keeps only the
+// rows after the cursor. NULLs sort first ascending and last descending on
+// every driver.
+func ApplyKeyset(q *gorm.DB, keys []SortKey, cursor string) (*gorm.DB, error) {
+	postgres := q.Dialector.Name() == "postgres"
+	for _, k := range keys {
+		order := k.Column + " asc"
+		if k.Desc {
+			order = k.Column + " desc"
+		}
+		// Postgres is the odd one out, make it match sqlite/mysql/sqlserver
+		if postgres {
+			if k.Desc {
+				order += " NULLS LAST"
+			} else {
+				order += " NULLS FIRST"
+			}
+		}
+		q = q.Order(order)
+	}
+
+	if cursor == "" {
+		return q, nil
+	}
+	values, err := decodeCursor(keys, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+	var disjuncts []string
+	var args []any
+	for i, k := range keys {
+		var conds []string
+		var condArgs []any
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				conds = append(conds, keys[j].Column+" IS NULL")
+			} else {
+				conds = append(conds, keys[j].Column+" = ?")
+				condArgs = append(condArgs, values[j])
+			}
+		}
+
+		switch {
+		case values[i] == nil && k.Desc:
+			// Nothing sorts after NULL in a descending key
+			continue
+		case values[i] == nil:
+			conds = append(conds, k.Column+" IS NOT NULL")
+		case k.Desc:
+			conds = append(conds, "("+k.Column+" < ? OR "+k.Column+" IS NULL)")
+			condArgs = append(condArgs, values[i])
+		default:
+			conds = append(conds, k.Column+" > ?")
+			condArgs = append(condArgs, values[i])
+		}
+		disjuncts = append(disjuncts, "("+strings.Join(conds, " AND ")+")")
+		args = append(args, condArgs...)
+	}
+	if len(disjuncts) == 0 {
+		return q.Where("1 = 0"), nil
+	}
+	return q.Where("("+strings.Join(disjuncts, " OR ")+")", args...), nil
+}
+
+// EncodeCursor builds the opaque cursor pointing after a row with the given
+// sort values (one per key)
+func EncodeCursor(keys []SortKey, values []any) string {
+	raw, _ := json.Marshal(cursorPayload{Sort: keysSignature(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// RowValues reads the sort values of keys from a struct row by json key,
+// keys that are not a field of the struct are left nil
+func RowValues(row any, keys []SortKey) []any {
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	t := v.Type()
+
+	values := make([]any, len(keys))
+	for i := 0; i < t.NumField(); i++ {
+		jsonTag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		for k, key := range keys {
+			if key.Key != jsonTag {
+				continue
+			}
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			values[k] = fv.Interface()
+		}
+	}
+	return values
+}
+
+func keysSignature(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Key
+		if k.Desc {
+			parts[i] = "-" + k.Key
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func decodeCursor(keys []SortKey, cursor string) ([]any, error) {
+	invalid := &FilterError{
+		Code:    ErrInvalidCursor,
+		Message: "Invalid cursor",
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var payload struct {
+		Sort   string            `json:"s"`
+		Values []json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || len(payload.Values) != len(keys) {
+		return nil, invalid
+	}
+	if payload.Sort != keysSignature(keys) {
+		return nil, &FilterError{
+			Code:    ErrInvalidCursor,
+			Message: "Cursor was built for a different sort, start again without a cursor",
+		}
+	}
+
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		if string(payload.Values[i]) == "null" {
+			continue
+		}
+		var err error
+		switch k.Type {
+		case DateTime:
+			var t time.Time
+			err = json.Unmarshal(payload.Values[i], &t)
+			values[i] = t
+		case Number:
+			d := json.NewDecoder(bytes.NewReader(payload.Values[i]))
+			d.UseNumber()
+			var n json.Number
+			if err = d.Decode(&n); err == nil {
+				if iv, ierr := n.Int64(); ierr == nil {
+					values[i] = iv
+				} else {
+					values[i], err = n.Float64()
+				}
+			}
+		case Boolean:
+			var b bool
+			err = json.Unmarshal(payload.Values[i], &b)
+			values[i] = b
+		default:
+			var s string
+			err = json.Unmarshal(payload.Values[i], &s)
+			values[i] = s
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
@@ -52,6 +52,11 @@ func BuildSchemaFromStruct(model any) map[string]FieldSchema {
 }
 
 func inferTypeAndOps(t reflect.Type) (FieldType, []Operator) {
+	// Nullable columns (*string, *time.Time, ...) filter like their element type
+	if t.Kind() == reflect.Ptr {
+		return inferTypeAndOps(t.Elem())
+	}
+
 	switch t {
 	case reflect.TypeOf(time.Time{}):
 		return DateTime, []Operator{Gt, Gte, Lt, Lte, Between, IsNull}
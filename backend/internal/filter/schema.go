@@ -39,6 +39,14 @@ type FieldSchema struct {
 	TimeFormat string     `json:"time_format,omitempty"`
 }
 
+// ReservedParams are query params consumed by the list handlers themselves,
+// they are never treated as filter fields
+var ReservedParams = []string{
+	"draw", "start", "length", "sort", "fields", "schema",
+	"tree", "depth", "replies_length", "q", "tag", "cursor", "count", "window",
+	"collection",
+}
+
 // FilterError represents a detailed filter error
 type FilterError struct {
 	Code     string     `json:"code"`
@@ -60,4 +68,5 @@ const (
 	ErrOperatorNotAllowed = "OPERATOR_NOT_ALLOWED"
 	ErrFieldNotSortable   = "FIELD_NOT_SORTABLE"
 	ErrInvalidFieldName   = "INVALID_FIELD_NAME"
+	ErrInvalidCursor      = "INVALID_CURSOR"
 )
@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_CATEGORIES_HANDLER lists categories in display order with their thread
+// count and last activity. Archived categories are included with ?archived=true
+func GET_CATEGORIES_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Order("position asc, name asc")
+		if c.Query("archived") != "true" {
+			query = query.Where("archived = ?", false)
+		}
+		var categories []model.Category
+		if err := query.Find(&categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get categories",
+			})
+			return
+		}
+
+		// Thread count and last activity (the newest thread or comment) per
+		// category in one query, over what everyone can see
+		threads := db.Model(&model.Thread{}).
+			Scopes(model.Visible("threads", nil)).
+			Select("threads.category, 1 AS is_thread, threads.created_at")
+		comments := db.Model(&model.Comment{}).
+			Scopes(model.Visible("comments", nil), model.Visible("threads", nil)).
+			Select("threads.category, 0 AS is_thread, comments.created_at").
+			Joins("JOIN threads ON threads.id = comments.thread_id").
+			Where("threads.deleted_at IS NULL")
+		var stats []struct {
+			Category     string
+			ThreadCount  int64
+			LastActivity string
+		}
+		db.Table("(?) AS activity", db.Raw("? UNION ALL ?", threads, comments)).
+			Select("category, SUM(is_thread) AS thread_count, MAX(created_at) AS last_activity").
+			Group("category").
+			Scan(&stats)
+		counts := map[string]int64{}
+		lastActivity := map[string]time.Time{}
+		for _, s := range stats {
+			counts[s.Category] = s.ThreadCount
+			lastActivity[s.Category] = parseDBTime(s.LastActivity)
+		}
+
+		data := make([]gin.H, 0, len(categories))
+		for _, cat := range categories {
+			var last any
+			if t, ok := lastActivity[cat.Slug]; ok && !t.IsZero() {
+				last = t
+			}
+			data = append(data, gin.H{
+				"id":               cat.ID,
+				"slug":             cat.Slug,
+				"name":             cat.Name,
+				"description":      cat.Description,
+				"color":            cat.Color,
+				"icon":             cat.Icon,
+				"position":         cat.Position,
+				"archived":         cat.Archived,
+				"thread_count":     counts[cat.Slug],
+				"last_activity_at": last,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    data,
+		})
+	}
+}
+
+// POST_CATEGORIES_HANDLER creates a category (super admin only)
+func POST_CATEGORIES_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		if user.RoleID != model.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "not authorized to manage categories",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		type CreateCategoryRequest struct {
+			Name        string `json:"name" binding:"required,max=100"`
+			Slug        string `json:"slug" binding:"max=100"`
+			Description string `json:"description"`
+			Color       string `json:"color" binding:"omitempty,hexcolor"`
+			Icon        string `json:"icon" binding:"max=50"`
+			Position    int    `json:"position"`
+		}
+		var req CreateCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+		if req.Slug == "" {
+			req.Slug = req.Name
+		}
+
+		category := model.Category{
+			Slug:        util.Slugify(req.Slug),
+			Name:        req.Name,
+			Description: req.Description,
+			Color:       req.Color,
+			Icon:        req.Icon,
+			Position:    req.Position,
+		}
+		if category.Slug == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid slug",
+				"message": "slug must contain letters or digits",
+				"data":    gin.H{},
+			})
+			return
+		}
+		var exists int64
+		db.Model(&model.Category{}).Where("slug = ?", category.Slug).Count(&exists)
+		if exists > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "slug taken",
+				"message": "category '" + category.Slug + "' already exists",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := db.Create(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to create category",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"message": "category created",
+			"data":    category,
+		})
+	}
+}
+
+// PUT_CATEGORIES_SLUG_HANDLER updates a category (super admin only). Renaming
+// the slug moves its threads and followers along.
+func PUT_CATEGORIES_SLUG_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		if user.RoleID != model.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "not authorized to manage categories",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var category model.Category
+		if err := db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "category not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		type UpdateCategoryRequest struct {
+			Name        *string `json:"name" binding:"omitempty,max=100"`
+			Slug        *string `json:"slug" binding:"omitempty,max=100"`
+			Description *string `json:"description"`
+			Color       *string `json:"color" binding:"omitempty,hexcolor"`
+			Icon        *string `json:"icon" binding:"omitempty,max=50"`
+			Position    *int    `json:"position"`
+			Archived    *bool   `json:"archived"`
+		}
+		var req UpdateCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		oldSlug := category.Slug
+		if req.Name != nil && *req.Name != "" {
+			category.Name = *req.Name
+		}
+		if req.Slug != nil {
+			category.Slug = util.Slugify(*req.Slug)
+			if category.Slug == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   "invalid slug",
+					"message": "slug must contain letters or digits",
+					"data":    gin.H{},
+				})
+				return
+			}
+			var exists int64
+			db.Model(&model.Category{}).Where("slug = ? AND id <> ?", category.Slug, category.ID).Count(&exists)
+			if exists > 0 {
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   "slug taken",
+					"message": "category '" + category.Slug + "' already exists",
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+		if req.Description != nil {
+			category.Description = *req.Description
+		}
+		if req.Color != nil {
+			category.Color = *req.Color
+		}
+		if req.Icon != nil {
+			category.Icon = *req.Icon
+		}
+		if req.Position != nil {
+			category.Position = *req.Position
+		}
+		if req.Archived != nil {
+			category.Archived = *req.Archived
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&category).Error; err != nil {
+				return err
+			}
+			if category.Slug != oldSlug {
+				if err := tx.Model(&model.Thread{}).Where("category = ?", oldSlug).UpdateColumn("category", category.Slug).Error; err != nil {
+					return err
+				}
+				return tx.Model(&model.Follow{}).
+					Where("target_type = ? AND target_id = ?", model.FollowCategory, oldSlug).
+					UpdateColumn("target_id", category.Slug).Error
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to update category",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "category updated",
+			"data":    category,
+		})
+	}
+}
+
+// DELETE_CATEGORIES_SLUG_HANDLER deletes a category (super admin only). A
+// category that still has threads needs ?move_to=<slug>, which merges it
+// into another category.
+func DELETE_CATEGORIES_SLUG_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		if user.RoleID != model.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "not authorized to manage categories",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var category model.Category
+		if err := db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "category not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var threadCount int64
+		db.Model(&model.Thread{}).Where("category = ?", category.Slug).Count(&threadCount)
+
+		moveTo := c.Query("move_to")
+		if threadCount > 0 {
+			if moveTo == "" {
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   "category not empty",
+					"message": "category still has threads, archive it or pass move_to=<slug>",
+					"data": gin.H{
+						"thread_count": threadCount,
+					},
+				})
+				return
+			}
+			var target model.Category
+			if err := db.Where("slug = ? AND id <> ?", moveTo, category.ID).First(&target).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "move_to category not found",
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if threadCount > 0 {
+				if err := tx.Model(&model.Thread{}).Where("category = ?", category.Slug).UpdateColumn("category", moveTo).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("target_type = ? AND target_id = ?", model.FollowCategory, category.Slug).Delete(&model.Follow{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&category).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to delete category",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "category deleted",
+			"data": gin.H{
+				"moved_threads": threadCount,
+			},
+		})
+	}
+}
+
+// parseDBTime reads a MAX() over a datetime column scanned into a string:
+// drivers hand back a time.Time, which database/sql formats as RFC 3339, but
+// sqlite returns its own text format
+func parseDBTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PUT_CATEGORIES_SLUG_FOLLOW_HANDLER makes the current user follow a
+// category: PUT follows, DELETE unfollows
+func PUT_CATEGORIES_SLUG_FOLLOW_HANDLER(db *gorm.DB, following bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var category model.Category
+		if err := db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "category not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := model.SetFollow(db, user.ID, model.FollowCategory, category.Slug, following); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to update follow",
+				"data":    gin.H{},
+			})
+			return
+		}
+		var followers int64
+		db.Model(&model.Follow{}).Where("target_type = ? AND target_id = ?", model.FollowCategory, category.Slug).Count(&followers)
+
+		message := "category followed"
+		if !following {
+			message = "category unfollowed"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+			"data": gin.H{
+				"slug":           category.Slug,
+				"following":      following,
+				"follower_count": followers,
+			},
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/filter"
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// commentsScope is threadsScope for flat comment lists
+type commentsScope func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool)
+
+// listComments is a flat, DataTables paged list of the comments in scope
+// across threads, newest first unless sorted otherwise. It takes the filter
+// and sort params of GET_THREADS_ID_COMMENTS_HANDLER.
+func listComments(db *gorm.DB, preload []string, scope commentsScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modelStruct := &model.Comment{}
+		schema := filter.BuildSchemaFromStruct(modelStruct)
+		if c.Query("schema") == "true" {
+			c.JSON(http.StatusOK, gin.H{
+				"schema": schema,
+			})
+			return
+		}
+
+		var req struct {
+			Draw   int    `form:"draw"`
+			Start  int    `form:"start"`
+			Length int    `form:"length"`
+			Sort   string `form:"sort"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 2000 {
+			req.Length = 2000
+		}
+		if req.Sort == "" {
+			req.Sort = "-createdAt"
+		}
+
+		// Anonymous when there is no valid token
+		viewer, _ := helper.GetFirebaseUser(c)
+		scoped, ok := scope(c, viewer)
+		if !ok {
+			return
+		}
+		visible := func(q *gorm.DB) *gorm.DB {
+			return scoped(model.Visible("comments", viewer)(q))
+		}
+		query := db.Model(modelStruct).Scopes(visible)
+		for _, p := range preload {
+			query = query.Preload(p)
+		}
+
+		var err error
+		query, err = filter.ApplyQueryFilters(query, c.Request.URL.Query(), schema)
+		if err != nil {
+			badFilterRequest(c, err)
+			return
+		}
+		var recordsFiltered int64
+		query.Count(&recordsFiltered)
+		query, err = filter.ApplySorting(query, req.Sort, schema)
+		if err != nil {
+			badFilterRequest(c, err)
+			return
+		}
+
+		var comments []model.Comment
+		if err := query.Offset(req.Start).Limit(req.Length).Find(&comments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		if user := viewer; user != nil && len(comments) > 0 {
+			var ids []string
+			for _, comment := range comments {
+				ids = append(ids, comment.ID)
+			}
+			var votes []model.CommentVote
+			db.Where("user_id = ? AND comment_id IN ?", user.ID, ids).Find(&votes)
+			voteMap := make(map[string]string) // commentID -> vote
+			for _, vote := range votes {
+				voteMap[vote.CommentID] = vote.VoteType
+			}
+			bookmarked := model.BookmarkedIDs(db, user.ID, model.BookmarkComment, ids)
+			for i, comment := range comments {
+				comments[i].BookmarkedByMe = bookmarked[comment.ID]
+				comments[i].UpVotedByMe = voteMap[comment.ID] == "up"
+				comments[i].DownVotedByMe = voteMap[comment.ID] == "down"
+			}
+		}
+
+		var recordsTotal int64
+		db.Model(modelStruct).Scopes(visible).Count(&recordsTotal)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":         true,
+			"draw":            req.Draw,
+			"recordsTotal":    recordsTotal,
+			"recordsFiltered": recordsFiltered,
+			"data":            comments,
+		})
+	}
+}
+
+// badFilterRequest answers a filter or sort param error with 400
+func badFilterRequest(c *gin.Context, err error) {
+	if filterErr, ok := err.(*filter.FilterError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": filterErr.Message,
+			"error":   filterErr,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"message": err.Error(),
+		"error":   err.Error(),
+	})
+}
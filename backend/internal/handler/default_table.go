@@ -360,8 +360,12 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 			Length int    `form:"length"`
 			Sort   string `form:"sort"`
 			Fields string `form:"fields"`
+			Cursor string `form:"cursor"` // keyset mode, empty for the first page
+			Count  string `form:"count"`  // count=false skips recordsTotal/recordsFiltered
 		}
 		_ = c.BindQuery(&req)
+		cursorMode := c.Request.URL.Query().Has("cursor")
+		withCount := req.Count != "false"
 
 		if req.Length <= 0 {
 			req.Length = 20
@@ -381,10 +385,10 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 		// Track which fields to select
 		// var selectedFields []string
 		var selectedJSONKeys []string
+		var dbColumns []string
 
 		if req.Fields != "" {
 			fields := strings.Split(req.Fields, ",")
-			dbColumns := []string{}
 
 			for _, f := range fields {
 				f = strings.TrimSpace(f)
@@ -438,11 +442,37 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 			return
 		}
 
+		// =============================
+		// 🔹 Count filtered
+		// =============================
+		var recordsFiltered int64
+		if withCount {
+			query.Count(&recordsFiltered)
+		}
+
 		// sort=-created_at  [desc created_at] | sort=created_at,name [asc created_at, asc name]
 		if req.Sort == "" {
 			req.Sort = "-id"
 		}
-		query, err = filter.ApplySorting(query, req.Sort, schema)
+		var keys []filter.SortKey
+		if cursorMode {
+			// Keyset pagination: order by the sort keys + id, continue after the cursor
+			keys, err = filter.KeysetSort(req.Sort, schema)
+			if err == nil {
+				query, err = filter.ApplyKeyset(query, keys, req.Cursor)
+			}
+			// The cursor is read from the rows, so its columns must be selected
+			if err == nil && len(dbColumns) > 0 {
+				for _, k := range keys {
+					if !util.Contains(dbColumns, k.Column) {
+						dbColumns = append(dbColumns, k.Column)
+					}
+				}
+				query = query.Select(dbColumns)
+			}
+		} else {
+			query, err = filter.ApplySorting(query, req.Sort, schema)
+		}
 		if err != nil {
 			if filterErr, ok := err.(*filter.FilterError); ok {
 				c.JSON(http.StatusBadRequest, gin.H{
@@ -461,17 +491,16 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 		}
 
 		// =============================
-		// 🔹 Count filtered
-		// =============================
-		var recordsFiltered int64
-		query.Count(&recordsFiltered)
-
-		// =============================
-		// 🔹 Pagination (DataTables)
+		// 🔹 Pagination (DataTables or cursor)
 		// =============================
-		query = query.
-			Offset(req.Start).
-			Limit(req.Length)
+		if cursorMode {
+			// One extra row tells whether there is a next page
+			query = query.Limit(req.Length + 1)
+		} else {
+			query = query.
+				Offset(req.Start).
+				Limit(req.Length)
+		}
 
 		// =============================
 		// 🔹 Execute
@@ -488,11 +517,23 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 			return
 		}
 
+		var nextCursor any
+		if cursorMode {
+			rows := reflect.ValueOf(results).Elem()
+			if rows.Len() > req.Length {
+				rows.Set(rows.Slice(0, req.Length))
+				last := rows.Index(req.Length - 1).Interface()
+				nextCursor = filter.EncodeCursor(keys, filter.RowValues(last, keys))
+			}
+		}
+
 		// =============================
 		// 🔹 Total records (tanpa filter)
 		// =============================
 		var recordsTotal int64
-		db.Model(model).Count(&recordsTotal)
+		if withCount {
+			db.Model(model).Count(&recordsTotal)
+		}
 
 		// =============================
 		// 🔹 Format response with field selection
@@ -536,13 +577,19 @@ func GET_DEFAULT_TABLE(db *gorm.DB, model interface{}, preload []string) gin.Han
 		// =============================
 		// 🔹 Response (DataTables)
 		// =============================
-		c.JSON(http.StatusOK, gin.H{
-			"success":         true,
-			"draw":            req.Draw,
-			"recordsTotal":    recordsTotal,
-			"recordsFiltered": recordsFiltered,
-			"data":            responseData,
-		})
+		resp := gin.H{
+			"success": true,
+			"draw":    req.Draw,
+			"data":    responseData,
+		}
+		if withCount {
+			resp["recordsTotal"] = recordsTotal
+			resp["recordsFiltered"] = recordsFiltered
+		}
+		if cursorMode {
+			resp["next_cursor"] = nextCursor
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
 
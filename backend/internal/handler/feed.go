@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_FEED_HANDLER lists the threads of the users and categories the current
+// user follows, and the threads followed users commented on. It answers like
+// GET_THREADS_HANDLER but always pages by cursor, newest first by default.
+func GET_FEED_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	list := listThreads(db, preload, func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool) {
+		if viewer == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "authorization required",
+			})
+			return nil, false
+		}
+		users := model.FollowedBy(db, viewer.ID, model.FollowUser)
+		categories := model.FollowedBy(db, viewer.ID, model.FollowCategory)
+		commented := db.Model(&model.Comment{}).
+			Scopes(model.Visible("comments", nil)).
+			Select("comments.thread_id").
+			Where("comments.user_id IN (?)", users)
+		return func(q *gorm.DB) *gorm.DB {
+			return q.Where("(threads.user_id IN (?) OR threads.category IN (?) OR threads.id IN (?))", users, categories, commented)
+		}, true
+	})
+
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if !query.Has("cursor") {
+			query.Set("cursor", "")
+		}
+		if query.Get("sort") == "" {
+			query.Set("sort", "-created_at")
+		}
+		c.Request.URL.RawQuery = query.Encode()
+		list(c)
+	}
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_ME_BOOKMARKS_HANDLER lists the threads the current user bookmarked,
+// with the filters, sorting and pagination of GET_THREADS_HANDLER.
+// ?collection=<slug> lists one collection.
+func GET_ME_BOOKMARKS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return listThreads(db, preload, func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool) {
+		bookmarks, ok := bookmarksOf(c, db, viewer, model.BookmarkThread)
+		if !ok {
+			return nil, false
+		}
+		return func(q *gorm.DB) *gorm.DB {
+			return q.Where("threads.id IN (?)", bookmarks)
+		}, true
+	})
+}
+
+// GET_ME_BOOKMARKS_COMMENTS_HANDLER lists the comments the current user
+// bookmarked, newest first unless sorted otherwise. It takes the filter and
+// sort params of the comment lists and ?collection=<slug>.
+func GET_ME_BOOKMARKS_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return listComments(db, preload, func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool) {
+		bookmarks, ok := bookmarksOf(c, db, viewer, model.BookmarkComment)
+		if !ok {
+			return nil, false
+		}
+		return func(q *gorm.DB) *gorm.DB {
+			return q.Where("comments.id IN (?)", bookmarks)
+		}, true
+	})
+}
+
+// bookmarksOf is the subquery of the target ids viewer bookmarked, limited to
+// the ?collection= of the request. It writes the error response and returns
+// false when the request cannot go on.
+func bookmarksOf(c *gin.Context, db *gorm.DB, viewer *model.User, targetType string) (*gorm.DB, bool) {
+	if viewer == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "authorization required",
+		})
+		return nil, false
+	}
+
+	bookmarks := db.Model(&model.Bookmark{}).
+		Select("target_id").
+		Where("user_id = ? AND target_type = ?", viewer.ID, targetType)
+	if slug := util.Slugify(c.Query("collection")); slug != "" {
+		var collection model.BookmarkCollection
+		if db.Where("user_id = ? AND slug = ?", viewer.ID, slug).Limit(1).Find(&collection).RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "unknown collection",
+				"message": "collection '" + c.Query("collection") + "' does not exist",
+				"data":    gin.H{},
+			})
+			return nil, false
+		}
+		bookmarks = bookmarks.Where("collection_id = ?", collection.ID)
+	}
+	return bookmarks, true
+}
+
+// GET_ME_BOOKMARKS_COLLECTIONS_HANDLER lists the current user's bookmark
+// collections with how many bookmarks each holds
+func GET_ME_BOOKMARKS_COLLECTIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var collections []model.BookmarkCollection
+		if err := db.Where("user_id = ?", user.ID).Order("name asc").Find(&collections).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get collections",
+			})
+			return
+		}
+
+		var counts []struct {
+			CollectionID *string
+			Count        int64
+		}
+		db.Model(&model.Bookmark{}).
+			Select("collection_id, COUNT(*) AS count").
+			Where("user_id = ?", user.ID).
+			Group("collection_id").
+			Scan(&counts)
+		countMap := map[string]int64{}
+		var unsorted int64
+		for _, row := range counts {
+			if row.CollectionID == nil {
+				unsorted = row.Count
+			} else {
+				countMap[*row.CollectionID] = row.Count
+			}
+		}
+
+		data := make([]gin.H, 0, len(collections))
+		for _, col := range collections {
+			data = append(data, gin.H{
+				"id":         col.ID,
+				"name":       col.Name,
+				"slug":       col.Slug,
+				"count":      countMap[col.ID],
+				"created_at": col.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"data":     data,
+			"unsorted": unsorted, // bookmarks outside any collection
+		})
+	}
+}
+
+// POST_ME_BOOKMARKS_COLLECTIONS_HANDLER creates a bookmark collection,
+// {"name": "<name>"}. Creating an existing name returns that collection.
+func POST_ME_BOOKMARKS_COLLECTIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var req struct {
+			Name string `json:"name" binding:"required,max=100"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if util.Slugify(req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid name",
+				"message": "name needs a letter or digit",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		collection, err := model.FindOrCreateCollection(db, user.ID, req.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to create collection",
+				"data":    gin.H{},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "collection created",
+			"data":    collection,
+		})
+	}
+}
+
+// DELETE_ME_BOOKMARKS_COLLECTIONS_ID_HANDLER deletes a bookmark collection,
+// its bookmarks are kept outside any collection
+func DELETE_ME_BOOKMARKS_COLLECTIONS_ID_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var collection model.BookmarkCollection
+		if err := db.Where("id = ? AND user_id = ?", c.Param("collectionId"), user.ID).First(&collection).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "collection not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&model.Bookmark{}).Where("collection_id = ?", collection.ID).UpdateColumn("collection_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&collection).Error
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to delete collection",
+				"data":    gin.H{},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "collection deleted",
+			"data": gin.H{
+				"id": collection.ID,
+			},
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_MODERATION_CLASSIFICATIONS_HANDLER lists what the LLM classifier made
+// of new posts, latest first. ?flagged=true keeps the flagged ones,
+// ?status=ok|unavailable, ?type=threads|comments and ?category= filter it,
+// start/length page like the DataTables lists.
+func GET_MODERATION_CLASSIFICATIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := getModerator(c); !ok {
+			return
+		}
+
+		var req struct {
+			Flagged  bool   `form:"flagged"`
+			Status   string `form:"status"`
+			Type     string `form:"type"`
+			Category string `form:"category"`
+			Start    int    `form:"start"`
+			Length   int    `form:"length"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 100 {
+			req.Length = 100
+		}
+
+		query := db.Model(&model.Classification{})
+		if req.Flagged {
+			query = query.Where("flagged <> ?", "")
+		}
+		if req.Status != "" {
+			query = query.Where("status = ?", req.Status)
+		}
+		if req.Type != "" {
+			query = query.Where("source_type = ?", req.Type)
+		}
+		if req.Category != "" {
+			query = query.Where("(flagged = ? OR flagged LIKE ? OR flagged LIKE ?)", req.Category, req.Category+",%", "%,"+req.Category+"%")
+		}
+		var recordsTotal int64
+		query.Count(&recordsTotal)
+
+		var classifications []model.Classification
+		if err := query.Order("created_at desc").
+			Offset(req.Start).
+			Limit(req.Length).
+			Find(&classifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get classifications",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"recordsTotal": recordsTotal,
+			"data":         classifications,
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/stream"
+	"microblog/backend/pkg/audit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// GET_MODERATION_REPORTS_HANDLER is the moderation queue: open reports with
+// the most reporters first. ?status=open|dismissed|actioned, ?type=threads|comments
+// and ?reason= filter it, start/length page like the DataTables lists.
+func GET_MODERATION_REPORTS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := getModerator(c); !ok {
+			return
+		}
+
+		var req struct {
+			Status string `form:"status"`
+			Type   string `form:"type"`
+			Reason string `form:"reason"`
+			Start  int    `form:"start"`
+			Length int    `form:"length"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Status == "" {
+			req.Status = model.ReportOpen
+		}
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 100 {
+			req.Length = 100
+		}
+
+		query := db.Model(&model.Report{}).Where("status = ?", req.Status)
+		if req.Type != "" {
+			query = query.Where("target_type = ?", req.Type)
+		}
+		if req.Reason != "" {
+			query = query.Where("id IN (?)", db.Model(&model.ReportEntry{}).Select("report_id").Where("reason = ?", req.Reason))
+		}
+		var recordsTotal int64
+		query.Count(&recordsTotal)
+
+		if req.Status == model.ReportOpen {
+			query = query.Order("report_count desc, last_reported_at desc")
+		} else {
+			query = query.Order("resolved_at desc")
+		}
+		var reports []model.Report
+		if err := query.Preload("TargetUser").
+			Preload("Entries").
+			Offset(req.Start).
+			Limit(req.Length).
+			Find(&reports).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get reports",
+			})
+			return
+		}
+
+		// The reported content, deleted or not
+		var threadIDs, commentIDs []string
+		for _, r := range reports {
+			if r.TargetType == model.ReportTargetComment {
+				commentIDs = append(commentIDs, r.TargetID)
+			} else {
+				threadIDs = append(threadIDs, r.TargetID)
+			}
+		}
+		targets := map[string]gin.H{}
+		if len(threadIDs) > 0 {
+			var threads []model.Thread
+			db.Unscoped().Select("id", "title", "body", "category", "moderation", "deleted_at").
+				Where("id IN ?", threadIDs).Find(&threads)
+			for _, t := range threads {
+				targets[t.ID] = gin.H{
+					"title":      t.Title,
+					"body":       t.Body,
+					"category":   t.Category,
+					"moderation": t.Moderation,
+					"deleted_at": t.DeletedAt,
+				}
+			}
+		}
+		if len(commentIDs) > 0 {
+			var comments []model.Comment
+			db.Unscoped().Select("id", "content", "moderation", "deleted_at").
+				Where("id IN ?", commentIDs).Find(&comments)
+			for _, cm := range comments {
+				targets[cm.ID] = gin.H{
+					"content":    cm.Content,
+					"moderation": cm.Moderation,
+					"deleted_at": cm.DeletedAt,
+				}
+			}
+		}
+
+		// What the LLM classifier made of the content, latest first
+		classifications := map[string]*model.Classification{}
+		if len(reports) > 0 {
+			var found []model.Classification
+			db.Where("source_id IN ?", append(append([]string{}, threadIDs...), commentIDs...)).
+				Order("created_at desc").
+				Find(&found)
+			for i, cl := range found {
+				if _, ok := classifications[cl.SourceID]; !ok {
+					classifications[cl.SourceID] = &found[i]
+				}
+			}
+		}
+
+		data := make([]gin.H, 0, len(reports))
+		for _, r := range reports {
+			reasons := map[string]int{}
+			notes := []string{}
+			for _, e := range r.Entries {
+				reasons[e.Reason]++
+				if e.Note != "" {
+					notes = append(notes, e.Note)
+				}
+			}
+			data = append(data, gin.H{
+				"id":             r.ID,
+				"target_type":    r.TargetType,
+				"target_id":      r.TargetID,
+				"thread_id":      r.ThreadID,
+				"target":         targets[r.TargetID],
+				"classification": classifications[r.TargetID],
+				"status":         r.Status,
+				"report_count":   r.ReportCount,
+				"reasons":        reasons,
+				"notes":          notes,
+				"target_user": gin.H{
+					"id":              r.TargetUser.ID,
+					"name":            r.TargetUser.Name,
+					"avatar":          r.TargetUser.Avatar,
+					"status":          r.TargetUser.Status,
+					"suspended_until": r.TargetUser.SuspendedUntil,
+					"warning_count":   r.TargetUser.WarningCount,
+				},
+				"action":           r.Action,
+				"note":             r.Note,
+				"resolved_at":      r.ResolvedAt,
+				"last_reported_at": r.LastReportedAt,
+				"created_at":       r.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"recordsTotal": recordsTotal,
+			"actions":      model.ModerationActions,
+			"data":         data,
+		})
+	}
+}
+
+// POST_MODERATION_REPORTS_ID_HANDLER closes an open report with one of
+// model.ModerationActions. Every action is written to the audit log with the
+// state before and after it.
+func POST_MODERATION_REPORTS_ID_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		moderator, ok := getModerator(c)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Action        string `json:"action" binding:"required"`
+			Note          string `json:"note"`
+			DurationHours int    `json:"duration_hours" binding:"min=0"` // suspend only, 0 is model.DefaultSuspension
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+		if !slices.Contains(model.ModerationActions, req.Action) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid action",
+				"message": "action must be one of " + strings.Join(model.ModerationActions, ", "),
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var report model.Report
+		if err := db.Where("id = ?", c.Param("reportId")).First(&report).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "report not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if report.Status != model.ReportOpen {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "report resolved",
+				"message": "the report was already " + report.Status,
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		suspension := model.DefaultSuspension
+		if req.DurationHours > 0 {
+			suspension = time.Duration(req.DurationHours) * time.Hour
+		}
+		// Dismissing the report of a held post releases it
+		var held int64
+		if req.Action == model.ModerateDismiss {
+			db.Table(report.TargetType).Where("id = ? AND moderation = ?", report.TargetID, model.ModerationHeld).Count(&held)
+		}
+		entry, err := moderate(db, &report, moderator, req.Action, strings.TrimSpace(req.Note), suspension)
+		if err != nil {
+			audit.Log(c, db, moderator.ID, entry.Failed(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to " + req.Action,
+				"data":    gin.H{},
+			})
+			return
+		}
+		audit.Log(c, db, moderator.ID, entry.Success(fmt.Sprintf("report %s: %s", report.ID, req.Action)))
+
+		if held > 0 {
+			announceReleased(db, report)
+		}
+
+		if req.Action == model.ModerateHide || req.Action == model.ModerateDelete {
+			if report.TargetType == model.ReportTargetComment {
+				stream.PublishForThread(db, stream.CommentDeleted, report.ThreadID, gin.H{
+					"id":        report.TargetID,
+					"thread_id": report.ThreadID,
+				})
+			} else {
+				stream.PublishForThread(db, stream.ThreadDeleted, report.ThreadID, gin.H{
+					"id": report.ThreadID,
+				})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "report " + report.Status,
+			"data": gin.H{
+				"id":     report.ID,
+				"status": report.Status,
+				"action": report.Action,
+			},
+		})
+	}
+}
+
+// moderationPenalties are the reputation reasons of the actions that cost
+// the reported author karma
+var moderationPenalties = map[string]string{
+	model.ModerateDelete:  model.RepContentRemoved,
+	model.ModerateWarn:    model.RepWarned,
+	model.ModerateSuspend: model.RepSuspended,
+}
+
+// moderate applies action to the reported content or its author and closes
+// the report, in one transaction. The returned audit entry holds what the
+// action changed, before and after.
+func moderate(db *gorm.DB, report *model.Report, moderator *model.User, action, note string, suspension time.Duration) (*audit.Entry, error) {
+	var entry *audit.Entry
+	before := gin.H{"report_status": report.Status}
+	after := gin.H{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		switch action {
+		case model.ModerateDismiss:
+			entry = audit.Update("reports", report.ID)
+			// A post held by the spam checks is published when its report is dismissed
+			released := tx.Table(report.TargetType).
+				Where("id = ? AND moderation = ?", report.TargetID, model.ModerationHeld).
+				UpdateColumn("moderation", model.ModerationVisible)
+			if released.Error != nil {
+				return released.Error
+			}
+			if released.RowsAffected > 0 {
+				before["moderation"] = model.ModerationHeld
+				after["moderation"] = model.ModerationVisible
+				if report.TargetType == model.ReportTargetComment {
+					var comment model.Comment
+					if err := tx.Where("id = ?", report.TargetID).First(&comment).Error; err != nil {
+						return err
+					}
+					if err := model.AwardCommentPosted(tx, comment); err != nil {
+						return err
+					}
+					comment.Recount(tx)
+				}
+			}
+
+		case model.ModerateHide, model.ModerateDelete:
+			if action == model.ModerateHide {
+				entry = audit.Update(report.TargetType, report.TargetID)
+			} else {
+				entry = audit.Delete(report.TargetType, report.TargetID)
+			}
+			var target any = &model.Thread{}
+			if report.TargetType == model.ReportTargetComment {
+				target = &model.Comment{}
+			}
+			if err := tx.Unscoped().Where("id = ?", report.TargetID).First(target).Error; err != nil {
+				return err
+			}
+			contentState(target, before)
+
+			moderation := model.ModerationHidden
+			if action == model.ModerateDelete {
+				moderation = model.ModerationRemoved
+			}
+			if err := tx.Unscoped().Model(target).UpdateColumn("moderation", moderation).Error; err != nil {
+				return err
+			}
+			if comment, ok := target.(*model.Comment); ok {
+				comment.Recount(tx)
+			}
+			// Delete through the model so the comments are tombstoned with it
+			if action == model.ModerateDelete && !isDeleted(target) {
+				if err := tx.Delete(target).Error; err != nil {
+					return err
+				}
+			}
+			if action == model.ModerateDelete && report.TargetType == model.ReportTargetComment {
+				if err := model.RevokeComments(tx, []string{report.TargetID}); err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id = ?", report.TargetID).First(target).Error; err != nil {
+				return err
+			}
+			contentState(target, after)
+
+		case model.ModerateWarn, model.ModerateSuspend:
+			entry = audit.Update("users", report.TargetUserID)
+			var user model.User
+			if err := tx.Where("id = ?", report.TargetUserID).First(&user).Error; err != nil {
+				return err
+			}
+			if action == model.ModerateWarn {
+				before["warning_count"] = user.WarningCount
+				if err := tx.Model(&user).UpdateColumn("warning_count", gorm.Expr("warning_count + 1")).Error; err != nil {
+					return err
+				}
+				warning := model.Notification{
+					UserID:     user.ID,
+					Type:       model.NotifyWarning,
+					GroupKey:   model.NotifyWarning + ":" + report.ID,
+					ThreadID:   report.ThreadID,
+					ActorID:    moderator.ID,
+					ActorCount: 1,
+					Note:       note,
+				}
+				if report.TargetType == model.ReportTargetComment {
+					warning.CommentID = &report.TargetID
+				}
+				if err := tx.Create(&warning).Error; err != nil {
+					return err
+				}
+				after["warning_count"] = user.WarningCount + 1
+				break
+			}
+
+			if user.IsModerator() {
+				return fmt.Errorf("moderators cannot be suspended")
+			}
+			until := time.Now().Add(suspension)
+			before["status"] = user.Status
+			before["suspended_until"] = user.SuspendedUntil
+			if err := tx.Model(&user).UpdateColumns(map[string]any{
+				"status":          model.StatusSuspended,
+				"suspended_until": until,
+			}).Error; err != nil {
+				return err
+			}
+			after["status"] = model.StatusSuspended
+			after["suspended_until"] = until
+		}
+
+		// Penalize the author in the reputation ledger, hiding is not a penalty
+		if reason, ok := moderationPenalties[action]; ok {
+			if err := model.AwardReputation(tx, model.ReputationEvent{
+				UserID:     report.TargetUserID,
+				ActorID:    moderator.ID,
+				Reason:     reason,
+				SourceType: "reports",
+				SourceID:   report.ID,
+				ThreadID:   report.ThreadID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		report.Status = model.ReportActioned
+		if action == model.ModerateDismiss {
+			report.Status = model.ReportDismissed
+		}
+		report.Action = action
+		report.Note = note
+		report.ResolvedByID = &moderator.ID
+		report.ResolvedAt = &now
+		after["report_status"] = report.Status
+		return tx.Model(report).Updates(map[string]any{
+			"status":         report.Status,
+			"action":         report.Action,
+			"note":           report.Note,
+			"resolved_by_id": report.ResolvedByID,
+			"resolved_at":    report.ResolvedAt,
+		}).Error
+	})
+	if entry == nil {
+		entry = audit.Update("reports", report.ID)
+	}
+	return entry.Before(before).After(after), err
+}
+
+// announceReleased streams a post released from the spam hold and notifies
+// about it, like the create handlers do for posts that were never held
+func announceReleased(db *gorm.DB, report model.Report) {
+	var thread model.Thread
+	if err := db.Preload("User").Preload("Tags").Where("id = ?", report.ThreadID).First(&thread).Error; err != nil {
+		logrus.Println(err)
+		return
+	}
+	if report.TargetType == model.ReportTargetThread {
+		stream.Publish(stream.ThreadCreated, thread.ID, thread.Category, gin.H{
+			"id":         thread.ID,
+			"title":      thread.Title,
+			"category":   thread.Category,
+			"tags":       thread.Tags,
+			"created_at": thread.CreatedAt,
+			"owner": gin.H{
+				"id":     thread.User.ID,
+				"name":   thread.User.Name,
+				"avatar": thread.User.Avatar,
+			},
+		})
+		return
+	}
+
+	var comment model.Comment
+	if err := db.Preload("User").Where("id = ?", report.TargetID).First(&comment).Error; err != nil {
+		logrus.Println(err)
+		return
+	}
+	if err := model.NotifyComment(db, thread, comment); err != nil {
+		logrus.Println(err)
+	}
+	if thread.Moderation != model.ModerationVisible {
+		return
+	}
+	stream.Publish(stream.CommentCreated, thread.ID, thread.Category, gin.H{
+		"id":           comment.ID,
+		"thread_id":    comment.ThreadID,
+		"parent_id":    comment.ParentID,
+		"content":      comment.Content,
+		"content_html": comment.ContentHTML,
+		"createdAt":    comment.CreatedAt.Format(time.RFC3339),
+		"owner": gin.H{
+			"id":     comment.User.ID,
+			"name":   comment.User.Name,
+			"avatar": comment.User.Avatar,
+		},
+	})
+}
+
+// contentState records the moderation state of a thread or comment into state
+func contentState(target any, state gin.H) {
+	switch t := target.(type) {
+	case *model.Thread:
+		state["moderation"] = t.Moderation
+		state["deleted_at"] = t.DeletedAt
+	case *model.Comment:
+		state["moderation"] = t.Moderation
+		state["deleted_at"] = t.DeletedAt
+	}
+}
+
+func isDeleted(target any) bool {
+	switch t := target.(type) {
+	case *model.Thread:
+		return t.DeletedAt.Valid
+	case *model.Comment:
+		return t.DeletedAt.Valid
+	}
+	return false
+}
+
+// getModerator returns the current user when they are a moderator or admin.
+// It writes the error response and returns false otherwise.
+func getModerator(c *gin.Context) (*model.User, bool) {
+	user, err := helper.GetFirebaseUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, false
+	}
+	if !user.IsModerator() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Forbidden",
+			"message": "only moderators can moderate reports",
+			"data":    gin.H{},
+		})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_NOTIFICATIONS_HANDLER lists the notifications of the current user,
+// newest activity first, with the unread count. ?unread=true lists only
+// unread ones, start/length page like the DataTables lists.
+func GET_NOTIFICATIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var req struct {
+			Start  int  `form:"start"`
+			Length int  `form:"length"`
+			Unread bool `form:"unread"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 100 {
+			req.Length = 100
+		}
+
+		query := db.Model(&model.Notification{}).Where("user_id = ?", user.ID)
+		if req.Unread {
+			query = query.Where("read_at IS NULL")
+		}
+		var recordsTotal int64
+		query.Count(&recordsTotal)
+
+		var notifications []model.Notification
+		if err := query.Preload("Actor").
+			Order("updated_at desc").
+			Offset(req.Start).
+			Limit(req.Length).
+			Find(&notifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get notifications",
+			})
+			return
+		}
+
+		var unread int64
+		db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", user.ID).Count(&unread)
+
+		data := make([]gin.H, 0, len(notifications))
+		for _, n := range notifications {
+			data = append(data, gin.H{
+				"id":          n.ID,
+				"type":        n.Type,
+				"message":     n.Message(),
+				"thread_id":   n.ThreadID,
+				"comment_id":  n.CommentID,
+				"actor_count": n.ActorCount,
+				"note":        n.Note,
+				"actor": gin.H{
+					"id":     n.Actor.ID,
+					"name":   n.Actor.Name,
+					"avatar": n.Actor.Avatar,
+				},
+				"read":       n.ReadAt != nil,
+				"read_at":    n.ReadAt,
+				"created_at": n.CreatedAt,
+				"updated_at": n.UpdatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"recordsTotal": recordsTotal,
+			"unread_count": unread,
+			"data":         data,
+		})
+	}
+}
+
+// POST_NOTIFICATIONS_READ_HANDLER marks notifications as read, the given
+// ids or all of them when no ids are sent
+func POST_NOTIFICATIONS_READ_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		// An empty body marks everything read
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": err.Error(),
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+
+		query := db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", user.ID)
+		if len(req.IDs) > 0 {
+			query = query.Where("id IN ?", req.IDs)
+		}
+		result := query.UpdateColumn("read_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   result.Error.Error(),
+				"message": "failed to mark notifications as read",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var unread int64
+		db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", user.ID).Count(&unread)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "notifications marked as read",
+			"data": gin.H{
+				"marked":       result.RowsAffected,
+				"unread_count": unread,
+			},
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"microblog/backend/internal/embedding"
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrSemanticSearchUnavailable is the error code of semantic searches
+// without an embedder to embed the query
+const ErrSemanticSearchUnavailable = "SEMANTIC_SEARCH_UNAVAILABLE"
+
+// GET_SEARCH_SEMANTIC_HANDLER finds the threads, or the comments with
+// ?type=comments, closest in meaning to ?q= by embedding similarity, so
+// paraphrased questions are found too. ?length= caps the results (10).
+func GET_SEARCH_SEMANTIC_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Q      string `form:"q"`
+			Type   string `form:"type"`
+			Length int    `form:"length"`
+		}
+		_ = c.BindQuery(&req)
+		req.Q = strings.TrimSpace(req.Q)
+		if req.Q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "q is required",
+				"message": "q is required",
+				"data":    []any{},
+			})
+			return
+		}
+		if req.Type == "" {
+			req.Type = embedding.SourceThread
+		}
+		if req.Type != embedding.SourceThread && req.Type != embedding.SourceComment {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid type",
+				"message": "type must be threads or comments",
+				"data":    []any{},
+			})
+			return
+		}
+		if req.Length <= 0 {
+			req.Length = 10
+		}
+		if req.Length > 50 {
+			req.Length = 50
+		}
+
+		// Ask for more than needed, some matches may not be visible
+		matches, err := embedding.Search(c.Request.Context(), db, req.Q, req.Type, 3*req.Length)
+		if err != nil {
+			if !errors.Is(err, llmClient.ErrNoProvider) {
+				logrus.Errorf("semantic search: %v", err)
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"error":   ErrSemanticSearchUnavailable,
+				"message": "semantic search is not available, try the keyword search",
+				"data":    []any{},
+			})
+			return
+		}
+
+		viewer, _ := helper.GetFirebaseUser(c)
+		var data []gin.H
+		if req.Type == embedding.SourceComment {
+			data = semanticComments(db, viewer, matches, req.Length)
+		} else {
+			data = semanticThreads(db, viewer, matches, req.Length)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    data,
+		})
+	}
+}
+
+// semanticThreads are the visible threads of matches, at most limit
+func semanticThreads(db *gorm.DB, viewer *model.User, matches []embedding.Match, limit int) []gin.H {
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.SourceID)
+	}
+	var threads []model.Thread
+	db.Scopes(model.Visible("threads", viewer)).
+		Preload("User").
+		Where("id IN ?", ids).
+		Find(&threads)
+	byID := map[string]model.Thread{}
+	for _, t := range threads {
+		byID[t.ID] = t
+	}
+
+	data := []gin.H{}
+	for _, m := range matches {
+		t, ok := byID[m.SourceID]
+		if !ok || len(data) == limit {
+			continue
+		}
+		data = append(data, gin.H{
+			"similarity": m.Similarity,
+			"thread":     relatedThread(t),
+		})
+	}
+	return data
+}
+
+// semanticComments are the visible comments of matches in visible threads,
+// at most limit
+func semanticComments(db *gorm.DB, viewer *model.User, matches []embedding.Match, limit int) []gin.H {
+	ids := make([]string, 0, len(matches))
+	threadIDs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.SourceID)
+		threadIDs = append(threadIDs, m.ThreadID)
+	}
+	var comments []model.Comment
+	db.Scopes(model.Visible("comments", viewer)).
+		Preload("User").
+		Where("id IN ?", ids).
+		Find(&comments)
+	var threads []model.Thread
+	db.Scopes(model.Visible("threads", viewer)).
+		Select("id", "title", "category").
+		Where("id IN ?", threadIDs).
+		Find(&threads)
+	commentByID := map[string]model.Comment{}
+	for _, cm := range comments {
+		commentByID[cm.ID] = cm
+	}
+	threadByID := map[string]model.Thread{}
+	for _, t := range threads {
+		threadByID[t.ID] = t
+	}
+
+	data := []gin.H{}
+	for _, m := range matches {
+		cm, ok := commentByID[m.SourceID]
+		t, visible := threadByID[m.ThreadID]
+		if !ok || !visible || len(data) == limit {
+			continue
+		}
+		data = append(data, gin.H{
+			"similarity": m.Similarity,
+			"comment": gin.H{
+				"id":           cm.ID,
+				"content":      cm.Content,
+				"content_html": cm.ContentHTML,
+				"createdAt":    cm.CreatedAt,
+				"owner": gin.H{
+					"id":     cm.User.ID,
+					"name":   cm.User.Name,
+					"avatar": cm.User.Avatar,
+				},
+			},
+			"thread": gin.H{
+				"id":       t.ID,
+				"title":    t.Title,
+				"category": t.Category,
+			},
+		})
+	}
+	return data
+}
+
+// relatedThread is the short form of a thread in search results and
+// related blocks
+func relatedThread(t model.Thread) gin.H {
+	return gin.H{
+		"id":             t.ID,
+		"title":          t.Title,
+		"category":       t.Category,
+		"created_at":     t.CreatedAt,
+		"total_comments": t.TotalComments,
+		"score":          t.Score,
+		"owner": gin.H{
+			"id":     t.User.ID,
+			"name":   t.User.Name,
+			"avatar": t.User.Avatar,
+		},
+	}
+}
+
+// RelatedThreads are the threads closest in meaning to a thread that viewer
+// can see, at most limit. Without embeddings there are none.
+func RelatedThreads(db *gorm.DB, viewer *model.User, threadID string, limit int) []gin.H {
+	matches, err := embedding.Related(db, threadID, 2*limit)
+	if err != nil {
+		if !errors.Is(err, llmClient.ErrNoProvider) {
+			logrus.Errorf("related threads of %s: %v", threadID, err)
+		}
+		return []gin.H{}
+	}
+	return semanticThreads(db, viewer, matches, limit)
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// streamPing is how often an idle connection gets a keep-alive
+const streamPing = 25 * time.Second
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Cross origin clients are allowed like the rest of the API (see CORS
+	// config), the Firebase token is what authorizes the connection
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// GET_STREAM_HANDLER pushes forum events as Server-Sent Events, or over a
+// WebSocket when the request is an upgrade (or ?transport=ws).
+//
+// Scope with ?thread_id= and/or ?category=. EventSource and WebSocket cannot
+// send headers from the browser, so the Firebase ID token may also be given
+// as ?token=. SSE clients resume with Last-Event-ID (or ?last_event_id=).
+func GET_STREAM_HANDLER(hub *stream.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.Query("token") != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+c.Query("token"))
+		}
+		if _, err := helper.GetFirebaseUser(c); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		scope := stream.Scope{
+			ThreadID: c.Query("thread_id"),
+			Category: c.Query("category"),
+		}
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		last, _ := strconv.ParseUint(lastID, 10, 64)
+
+		if websocket.IsWebSocketUpgrade(c.Request) || c.Query("transport") == "ws" {
+			serveWebSocket(c, hub, scope, last)
+			return
+		}
+		serveSSE(c, hub, scope, last)
+	}
+}
+
+func serveSSE(c *gin.Context, hub *stream.Hub, scope stream.Scope, lastID uint64) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "streaming unsupported",
+		})
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+	c.Status(http.StatusOK)
+
+	sub := hub.Subscribe(scope, lastID)
+	defer hub.Unsubscribe(sub)
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for being too slow, the client reconnects and catches up
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func serveWebSocket(c *gin.Context, hub *stream.Hub, scope stream.Scope, lastID uint64) {
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	sub := hub.Subscribe(scope, lastID)
+	defer hub.Unsubscribe(sub)
+
+	// The stream is one way, reading only notices close frames and dead peers
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(2 * streamPing))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * streamPing))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"),
+					time.Now().Add(time.Second))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"microblog/backend/internal/filter"
 	"microblog/backend/internal/helper"
 	"microblog/backend/internal/model"
+	"microblog/backend/internal/ranking"
+	"microblog/backend/internal/search"
 	"microblog/backend/pkg/util"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,6 +19,17 @@ import (
 
 // Ensure this not column name draw, start, length, sort, schema
 func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return listThreads(db, preload, nil)
+}
+
+// threadsScope narrows a threads listing down for the viewer, e.g. to their
+// bookmarks. It writes the error response and returns false when the request
+// cannot go on.
+type threadsScope func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool)
+
+// listThreads is GET_THREADS_HANDLER over the threads in scope, nil scope
+// lists every thread
+func listThreads(db *gorm.DB, preload []string, scope threadsScope) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		modelStruct := &model.Thread{}
 		// =============================
@@ -28,7 +42,8 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		// =============================
 		if c.Query("schema") == "true" {
 			c.JSON(http.StatusOK, gin.H{
-				"schema": schema,
+				"schema":  schema,
+				"ranking": ranking.Modes,
 			})
 			return
 		}
@@ -42,8 +57,16 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 			Length int    `form:"length"`
 			Sort   string `form:"sort"`
 			Fields string `form:"fields"`
+			Q      string `form:"q"`      // full-text search on title, body and comments
+			Tag    string `form:"tag"`    // comma-separated tag slugs, any of them matches
+			Cursor string `form:"cursor"` // keyset mode, empty for the first page
+			Count  string `form:"count"`  // count=false skips recordsTotal/recordsFiltered
+			Window string `form:"window"` // time window of sort=top|controversial
 		}
 		_ = c.BindQuery(&req)
+		req.Q = strings.TrimSpace(req.Q)
+		cursorMode := c.Request.URL.Query().Has("cursor")
+		withCount := req.Count != "false"
 
 		if req.Length <= 0 {
 			req.Length = 20
@@ -55,7 +78,19 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		// =============================
 		// 🔹 Base query + preload
 		// =============================
-		query := db.Model(modelStruct)
+		// Anonymous when there is no valid token
+		viewer, _ := helper.GetFirebaseUser(c)
+		visible := model.Visible("threads", viewer)
+		if scope != nil {
+			scoped, ok := scope(c, viewer)
+			if !ok {
+				return
+			}
+			visible = func(q *gorm.DB) *gorm.DB {
+				return scoped(model.Visible("threads", viewer)(q))
+			}
+		}
+		query := db.Model(modelStruct).Scopes(visible)
 		for _, p := range preload {
 			query = query.Preload(p)
 		}
@@ -63,10 +98,10 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		// Track which fields to select
 		// var selectedFields []string
 		var selectedJSONKeys []string
+		var dbColumns []string
 
 		if req.Fields != "" {
 			fields := strings.Split(req.Fields, ",")
-			dbColumns := []string{}
 
 			for _, f := range fields {
 				f = strings.TrimSpace(f)
@@ -120,11 +155,105 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 			return
 		}
 
+		// =============================
+		// 🔹 Tag filter (tag=go,web)
+		// =============================
+		if req.Tag != "" {
+			var slugs []string
+			for _, t := range strings.Split(req.Tag, ",") {
+				if slug := util.Slugify(t); slug != "" {
+					slugs = append(slugs, slug)
+				}
+			}
+			query = query.Where("threads.id IN (?)", db.Table("thread_tags").
+				Select("thread_tags.thread_id").
+				Joins("JOIN tags ON tags.id = thread_tags.tag_id").
+				Where("tags.slug IN ?", slugs))
+		}
+
+		// =============================
+		// 🔹 Full-text search (q)
+		// =============================
+		if req.Q != "" {
+			query = search.Apply(db, query, req.Q)
+		}
+
+		// =============================
+		// 🔹 Ranking modes (sort=hot|top|rising|controversial)
+		// =============================
+		if mode, ok := ranking.Lookup(req.Sort); ok {
+			since, err := mode.Since(req.Window, time.Now())
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": err.Error(),
+					"error":   err.Error(),
+				})
+				return
+			}
+			if !since.IsZero() {
+				query = query.Where("threads.created_at >= ?", since)
+			}
+			req.Sort = mode.Sort
+		}
+
+		// =============================
+		// 🔹 Count filtered
+		// =============================
+		var recordsFiltered int64
+		if withCount {
+			query.Count(&recordsFiltered)
+		}
+
+		// Best matches first unless the client asked for another order
+		rankFirst := req.Q != "" && req.Sort == ""
+
 		// sort=-created_at  [desc created_at] | sort=created_at,name [asc created_at, asc name]
 		if req.Sort == "" {
 			req.Sort = "-id"
 		}
-		query, err = filter.ApplySorting(query, req.Sort, schema)
+		// Pinned threads lead whatever the sort, category pins only when
+		// listing that category
+		pins := []filter.SortKey{{Key: "pinned", Column: "threads.pinned", Type: filter.Boolean, Desc: true}}
+		if c.Query("category") != "" {
+			pins = append(pins, filter.SortKey{Key: "pinned_in_category", Column: "threads.pinned_in_category", Type: filter.Boolean, Desc: true})
+		}
+
+		var keys []filter.SortKey
+		if cursorMode {
+			// Keyset pagination: order by the sort keys + id, continue after the cursor
+			keys, err = filter.KeysetSort(req.Sort, schema)
+			if err == nil && rankFirst {
+				keys = append([]filter.SortKey{{
+					Key:    "search_rank",
+					Column: search.Alias + ".search_rank",
+					Type:   filter.Number,
+					Desc:   true,
+				}}, keys...)
+			}
+			if err == nil {
+				keys = append(pins, keys...)
+				query, err = filter.ApplyKeyset(query, keys, req.Cursor)
+			}
+			// The cursor is read from the rows, so its columns must be
+			// selected, search_rank included
+			if err == nil && len(dbColumns) > 0 {
+				for _, k := range keys {
+					if !util.Contains(dbColumns, k.Column) {
+						dbColumns = append(dbColumns, k.Column)
+					}
+				}
+				query = query.Select(dbColumns)
+			}
+		} else {
+			for _, k := range pins {
+				query = query.Order(k.Column + " desc")
+			}
+			if rankFirst {
+				query = query.Order(search.Alias + ".search_rank desc")
+			}
+			query, err = filter.ApplySorting(query, req.Sort, schema)
+		}
 		if err != nil {
 			if filterErr, ok := err.(*filter.FilterError); ok {
 				c.JSON(http.StatusBadRequest, gin.H{
@@ -143,23 +272,25 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		}
 
 		// =============================
-		// 🔹 Count filtered
-		// =============================
-		var recordsFiltered int64
-		query.Count(&recordsFiltered)
-
-		// =============================
-		// 🔹 Pagination (DataTables)
+		// 🔹 Pagination (DataTables or cursor)
 		// =============================
-		query = query.
-			Offset(req.Start).
-			Limit(req.Length)
+		if cursorMode {
+			// One extra row tells whether there is a next page
+			query = query.Limit(req.Length + 1)
+		} else {
+			query = query.
+				Offset(req.Start).
+				Limit(req.Length)
+		}
 
 		// =============================
 		// 🔹 Execute
 		// =============================
 		var results []model.Thread
 
+		if req.Q != "" && req.Fields == "" {
+			query = query.Select("threads.*, " + search.Alias + ".search_rank")
+		}
 		if err := query.Find(&results).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -168,11 +299,26 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 			return
 		}
 
+		var nextCursor any
+		if cursorMode && len(results) > req.Length {
+			results = results[:req.Length]
+			last := results[len(results)-1]
+			values := filter.RowValues(last, keys)
+			for i, k := range keys {
+				if k.Key == "search_rank" {
+					values[i] = last.SearchRank
+				}
+			}
+			nextCursor = filter.EncodeCursor(keys, values)
+		}
+
 		// =============================
 		// 🔹 Total records (tanpa filter)
 		// =============================
 		var recordsTotal int64
-		db.Model(modelStruct).Count(&recordsTotal)
+		if withCount {
+			db.Model(modelStruct).Scopes(visible).Count(&recordsTotal)
+		}
 
 		// =============================
 		// 🔹 Format response with field selection
@@ -182,7 +328,7 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		if len(selectedJSONKeys) > 0 {
 			// Only return selected fields
 			t := reflect.TypeOf(modelStruct).Elem()
-			sliceValue := reflect.ValueOf(results).Elem()
+			sliceValue := reflect.ValueOf(&results).Elem()
 			data := make([]gin.H, 0, sliceValue.Len())
 
 			// Build map of JSON keys to field indices
@@ -213,7 +359,19 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 			responseData = cleanupEmptyRelations(&results, preload)
 		}
 
-		if user, err := helper.GetFirebaseUser(c); err == nil {
+		if req.Q != "" {
+			var ids []string
+			for _, thread := range results {
+				ids = append(ids, thread.ID)
+			}
+			snippets := search.Snippets(db, ids, req.Q)
+			for i, thread := range results {
+				responseData[i]["search_rank"] = thread.SearchRank
+				responseData[i]["search_snippet"] = snippets[thread.ID]
+			}
+		}
+
+		if user := viewer; user != nil {
 			var ids []string
 			for _, thread := range results {
 				ids = append(ids, thread.ID)
@@ -226,7 +384,11 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 			}
 			fmt.Println("votes")
 			fmt.Println(votes)
+			bookmarked := model.BookmarkedIDs(db, user.ID, model.BookmarkThread, ids)
 			for i, thread := range responseData {
+				if bookmarked[thread["id"].(string)] {
+					responseData[i]["bookmarked_by_me"] = true
+				}
 				if voteMap[thread["id"].(string)] == "up" {
 					responseData[i]["up_voted_by_me"] = true
 				}
@@ -239,12 +401,18 @@ func GET_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
 		// =============================
 		// 🔹 Response (DataTables)
 		// =============================
-		c.JSON(http.StatusOK, gin.H{
-			"success":         true,
-			"draw":            req.Draw,
-			"recordsTotal":    recordsTotal,
-			"recordsFiltered": recordsFiltered,
-			"data":            responseData,
-		})
+		resp := gin.H{
+			"success": true,
+			"draw":    req.Draw,
+			"data":    responseData,
+		}
+		if withCount {
+			resp["recordsTotal"] = recordsTotal
+			resp["recordsFiltered"] = recordsFiltered
+		}
+		if cursorMode {
+			resp["next_cursor"] = nextCursor
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
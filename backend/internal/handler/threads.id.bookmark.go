@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PUT_THREADS_ID_BOOKMARK_HANDLER saves a thread for the current user: PUT
+// saves it, into {"collection": "<name>"} when given, DELETE unsaves it
+func PUT_THREADS_ID_BOOKMARK_HANDLER(db *gorm.DB, saved bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var thread model.Thread
+		if err := db.Scopes(model.Visible("threads", user)).Select("id").Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		setBookmark(c, db, saved, model.Bookmark{
+			UserID:     user.ID,
+			TargetType: model.BookmarkThread,
+			TargetID:   thread.ID,
+			ThreadID:   thread.ID,
+		})
+	}
+}
+
+// PUT_THREADS_ID_COMMENTS_ID_BOOKMARK_HANDLER is PUT_THREADS_ID_BOOKMARK_HANDLER
+// for a comment
+func PUT_THREADS_ID_COMMENTS_ID_BOOKMARK_HANDLER(db *gorm.DB, saved bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var comment model.Comment
+		if err := db.Scopes(model.Visible("comments", user)).
+			Select("id", "thread_id").
+			Where("id = ? AND thread_id = ?", c.Param("commentId"), c.Param("threadId")).
+			First(&comment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		setBookmark(c, db, saved, model.Bookmark{
+			UserID:     user.ID,
+			TargetType: model.BookmarkComment,
+			TargetID:   comment.ID,
+			ThreadID:   comment.ThreadID,
+		})
+	}
+}
+
+func setBookmark(c *gin.Context, db *gorm.DB, saved bool, bookmark model.Bookmark) {
+	if !saved {
+		if err := db.Where("user_id = ? AND target_type = ? AND target_id = ?", bookmark.UserID, bookmark.TargetType, bookmark.TargetID).
+			Delete(&model.Bookmark{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to remove bookmark",
+				"data":    gin.H{},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "bookmark removed",
+			"data": gin.H{
+				"target_type": bookmark.TargetType,
+				"target_id":   bookmark.TargetID,
+				"bookmarked":  false,
+			},
+		})
+		return
+	}
+
+	var req struct {
+		Collection string `json:"collection" binding:"max=100"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+	}
+	if name := strings.TrimSpace(req.Collection); name != "" {
+		if util.Slugify(name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid collection",
+				"message": "collection name needs a letter or digit",
+				"data":    gin.H{},
+			})
+			return
+		}
+		collection, err := model.FindOrCreateCollection(db, bookmark.UserID, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to find collection",
+				"data":    gin.H{},
+			})
+			return
+		}
+		bookmark.CollectionID = &collection.ID
+	}
+
+	bookmark, err := model.SaveBookmark(db, bookmark)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to save bookmark",
+			"data":    gin.H{},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "bookmark saved",
+		"data": gin.H{
+			"id":          bookmark.ID,
+			"target_type": bookmark.TargetType,
+			"target_id":   bookmark.TargetID,
+			"thread_id":   bookmark.ThreadID,
+			"collection":  bookmark.Collection,
+			"bookmarked":  true,
+			"created_at":  bookmark.CreatedAt,
+		},
+	})
+}
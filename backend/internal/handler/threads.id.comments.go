@@ -42,6 +42,10 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 			Length int    `form:"length"`
 			Sort   string `form:"sort"`
 			Fields string `form:"fields"`
+			// tree=true nests replies under their parent comment
+			Tree          bool `form:"tree"`
+			Depth         int  `form:"depth"`
+			RepliesLength int  `form:"replies_length"`
 		}
 		_ = c.BindQuery(&req)
 
@@ -51,11 +55,40 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 		if req.Length > 2000 {
 			req.Length = 2000
 		}
+		if req.Depth <= 0 {
+			req.Depth = 3
+		}
+		if req.Depth > 10 {
+			req.Depth = 10
+		}
+		if req.RepliesLength <= 0 {
+			req.RepliesLength = 3
+		}
+		if req.RepliesLength > 50 {
+			req.RepliesLength = 50
+		}
 
 		// =============================
 		// 🔹 Base query + preload
 		// =============================
-		query := db.Model(modelStruct)
+		threadID := c.Param("threadId")
+		viewer, _ := helper.GetFirebaseUser(c)
+		// Comments of a hidden or held thread are as hidden as the thread
+		if err := db.Scopes(model.Visible("threads", viewer)).Select("id").Where("id = ?", threadID).First(&model.Thread{}).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+			})
+			return
+		}
+		visible := model.Visible("comments", viewer)
+		query := db.Model(modelStruct).Scopes(visible).Where("thread_id = ?", threadID)
+		// In tree mode the page holds top-level comments, unless the client
+		// pages through the replies of one comment with parent_id=<id>
+		if req.Tree && !hasFilterParam(c.Request.URL.Query(), "parent_id") {
+			query = query.Where("parent_id IS NULL")
+		}
 		for _, p := range preload {
 			query = query.Preload(p)
 		}
@@ -172,7 +205,7 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 		// 🔹 Total records (tanpa filter)
 		// =============================
 		var recordsTotal int64
-		db.Model(modelStruct).Count(&recordsTotal)
+		db.Model(modelStruct).Scopes(visible).Where("thread_id = ?", threadID).Count(&recordsTotal)
 
 		// =============================
 		// 🔹 Format response with field selection
@@ -213,10 +246,25 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 			responseData = cleanupEmptyRelations(&results, preload)
 		}
 
-		if user, err := helper.GetFirebaseUser(c); err == nil {
+		// =============================
+		// 🔹 Nest replies (tree mode)
+		// =============================
+		allNodes := responseData
+		if req.Tree {
+			allNodes, err = attachCommentReplies(db, responseData, req.Depth, req.RepliesLength, preload, visible)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+				})
+				return
+			}
+		}
+
+		if user := viewer; user != nil {
 			var ids []string
-			for _, comment := range results {
-				ids = append(ids, comment.ID)
+			for _, comment := range allNodes {
+				ids = append(ids, comment["id"].(string))
 			}
 			var votes []model.CommentVote
 			db.Where("user_id = ? AND comment_id IN ?", user.ID, ids).Find(&votes)
@@ -226,12 +274,16 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 			}
 			fmt.Println("votes")
 			fmt.Println(votes)
-			for i, comment := range responseData {
+			bookmarked := model.BookmarkedIDs(db, user.ID, model.BookmarkComment, ids)
+			for i, comment := range allNodes {
+				if bookmarked[comment["id"].(string)] {
+					allNodes[i]["bookmarked_by_me"] = true
+				}
 				if voteMap[comment["id"].(string)] == "up" {
-					responseData[i]["up_voted_by_me"] = true
+					allNodes[i]["up_voted_by_me"] = true
 				}
 				if voteMap[comment["id"].(string)] == "down" {
-					responseData[i]["down_voted_by_me"] = true
+					allNodes[i]["down_voted_by_me"] = true
 				}
 			}
 		}
@@ -248,3 +300,67 @@ func GET_THREADS_ID_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerF
 		})
 	}
 }
+
+// attachCommentReplies nests up to limit replies under every node, depth
+// levels deep. Nodes whose replies were cut off get has_more_replies so the
+// client can page them with parent_id=<id>. It returns every node of the
+// tree as a flat list, parents first.
+func attachCommentReplies(db *gorm.DB, nodes []gin.H, depth, limit int, preload []string, visible func(*gorm.DB) *gorm.DB) ([]gin.H, error) {
+	all := nodes
+	level := nodes
+	for d := 0; d < depth && len(level) > 0; d++ {
+		byID := make(map[string]gin.H, len(level))
+		ids := make([]string, 0, len(level))
+		for _, n := range level {
+			id, _ := n["id"].(string)
+			byID[id] = n
+			ids = append(ids, id)
+			n["replies"] = []gin.H{}
+		}
+
+		ranked := db.Model(&model.Comment{}).
+			Select("comments.*, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at, id) AS reply_rank").
+			Scopes(visible).
+			Where("parent_id IN ?", ids)
+		query := db.Table("(?) AS ranked", ranked).
+			Where("reply_rank <= ?", limit).
+			Order("created_at, id")
+		for _, p := range preload {
+			query = query.Preload(p)
+		}
+		var replies []model.Comment
+		if err := query.Find(&replies).Error; err != nil {
+			return nil, err
+		}
+
+		next := cleanupEmptyRelations(&replies, preload)
+		for i, reply := range replies {
+			parent := byID[*reply.ParentID]
+			parent["replies"] = append(parent["replies"].([]gin.H), next[i])
+		}
+		for _, n := range level {
+			total, _ := n["total_replies"].(int)
+			n["has_more_replies"] = total > len(n["replies"].([]gin.H))
+		}
+
+		all = append(all, next...)
+		level = next
+	}
+	for _, n := range level {
+		if _, ok := n["replies"]; !ok {
+			total, _ := n["total_replies"].(int)
+			n["has_more_replies"] = total > 0
+		}
+	}
+	return all, nil
+}
+
+// hasFilterParam reports whether the query string filters on field with any operator
+func hasFilterParam(query map[string][]string, field string) bool {
+	for key := range query {
+		if key == field || strings.HasPrefix(key, field+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PUT_THREADS_ID_COMMENTS_ID_ACCEPT_HANDLER marks a comment as the answer to
+// its thread: PUT accepts it in place of any accepted before, DELETE
+// unaccepts it. Thread authors only, the comment's author earns
+// model.RepAnswerAccepted.
+func PUT_THREADS_ID_COMMENTS_ID_ACCEPT_HANDLER(db *gorm.DB, accepted bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var thread model.Thread
+		if err := db.Scopes(model.Visible("threads", user)).Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if thread.UserID != user.ID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "only the thread author can accept an answer",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		// Held and hidden comments cannot be the answer
+		var comment model.Comment
+		if err := db.Where("id = ? AND thread_id = ? AND moderation = ?", c.Param("commentId"), thread.ID, model.ModerationVisible).
+			First(&comment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		answer := &comment
+		message := "answer accepted"
+		if !accepted {
+			answer = nil
+			message = "answer unaccepted"
+			if thread.AcceptedCommentID == nil || *thread.AcceptedCommentID != comment.ID {
+				// Nothing to undo, another comment or none is accepted
+				c.JSON(http.StatusOK, gin.H{
+					"success": true,
+					"message": message,
+					"data": gin.H{
+						"thread_id":           thread.ID,
+						"accepted_comment_id": thread.AcceptedCommentID,
+					},
+				})
+				return
+			}
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return model.AcceptAnswer(tx, thread, answer)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to update the accepted answer",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var acceptedID *string
+		if answer != nil {
+			acceptedID = &answer.ID
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+			"data": gin.H{
+				"thread_id":           thread.ID,
+				"accepted_comment_id": acceptedID,
+			},
+		})
+	}
+}
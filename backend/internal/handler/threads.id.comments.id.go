@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_THREADS_ID_COMMENTS_ID_HANDLER returns a single comment (permalink) with
+// its ancestors, root first, and its replies nested like tree mode
+func GET_THREADS_ID_COMMENTS_ID_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		threadID := c.Param("threadId")
+		commentID := c.Param("commentId")
+
+		var req struct {
+			Depth         int `form:"depth"`
+			RepliesLength int `form:"replies_length"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Depth <= 0 {
+			req.Depth = 3
+		}
+		if req.Depth > 10 {
+			req.Depth = 10
+		}
+		if req.RepliesLength <= 0 {
+			req.RepliesLength = 3
+		}
+		if req.RepliesLength > 50 {
+			req.RepliesLength = 50
+		}
+
+		viewer, _ := helper.GetFirebaseUser(c)
+		if err := db.Scopes(model.Visible("threads", viewer)).Select("id").Where("id = ?", threadID).First(&model.Thread{}).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		visible := model.Visible("comments", viewer)
+		query := db.Scopes(visible).Where("id = ? AND thread_id = ?", commentID, threadID)
+		for _, p := range preload {
+			query = query.Preload(p)
+		}
+		comments := make([]model.Comment, 1)
+		if err := query.First(&comments[0]).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		// Walk up the parent chain, depth bounds the number of lookups
+		var ancestors []model.Comment
+		parentID := comments[0].ParentID
+		for i := 0; i < comments[0].Depth && parentID != nil; i++ {
+			var parent model.Comment
+			q := db.Scopes(visible).Where("id = ?", *parentID)
+			for _, p := range preload {
+				q = q.Preload(p)
+			}
+			if err := q.First(&parent).Error; err != nil {
+				break
+			}
+			ancestors = append([]model.Comment{parent}, ancestors...)
+			parentID = parent.ParentID
+		}
+
+		nodes := cleanupEmptyRelations(&comments, preload)
+		ancestorNodes := cleanupEmptyRelations(&ancestors, preload)
+		allNodes, err := attachCommentReplies(db, nodes, req.Depth, req.RepliesLength, preload, visible)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		allNodes = append(allNodes, ancestorNodes...)
+
+		if user := viewer; user != nil {
+			var ids []string
+			for _, n := range allNodes {
+				ids = append(ids, n["id"].(string))
+			}
+			var votes []model.CommentVote
+			db.Where("user_id = ? AND comment_id IN ?", user.ID, ids).Find(&votes)
+			voteMap := make(map[string]string) // commentID -> vote
+			for _, vote := range votes {
+				voteMap[vote.CommentID] = vote.VoteType
+			}
+			bookmarked := model.BookmarkedIDs(db, user.ID, model.BookmarkComment, ids)
+			for i, n := range allNodes {
+				if bookmarked[n["id"].(string)] {
+					allNodes[i]["bookmarked_by_me"] = true
+				}
+				if voteMap[n["id"].(string)] == "up" {
+					allNodes[i]["up_voted_by_me"] = true
+				}
+				if voteMap[n["id"].(string)] == "down" {
+					allNodes[i]["down_voted_by_me"] = true
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"comment":   nodes[0],
+				"ancestors": ancestorNodes,
+			},
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/spam"
+	"microblog/backend/internal/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// POST_THREADS_ID_COMMENTS_ID_REPLIES_HANDLER creates a reply to a specific comment
+func POST_THREADS_ID_COMMENTS_ID_REPLIES_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		threadID := c.Param("threadId")
+		commentID := c.Param("commentId")
+
+		user, ok := helper.GetPostingUser(c)
+		if !ok {
+			return
+		}
+
+		// Find the thread and parent comment, those the user cannot see do not exist
+		var thread model.Thread
+		if err := db.Scopes(model.Visible("threads", user)).Where("id = ?", threadID).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread is not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		var parent model.Comment
+		if err := db.Scopes(model.Visible("comments", user)).Where("id = ? AND thread_id = ?", commentID, thread.ID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment is not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if !helper.CheckThreadOpen(c, threadID, user) {
+			return
+		}
+
+		// Parse request
+		type CreateReplyRequest struct {
+			Content string `json:"content" binding:"required"`
+		}
+		var req CreateReplyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		moderation, checks, ok := helper.CheckContent(c, user, spam.Post{Type: "comments", Body: req.Content})
+		if !ok {
+			return
+		}
+
+		// Create reply object
+		reply := model.Comment{
+			ID:         uuid.New().String(),
+			Content:    req.Content,
+			CreatedAt:  time.Now(),
+			UserID:     user.ID,
+			ThreadID:   parent.ThreadID,
+			ParentID:   &parent.ID,
+			Depth:      parent.Depth + 1,
+			Moderation: moderation,
+		}
+
+		// Save to DB
+		if err := db.Create(&reply).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to create reply",
+				"data":    gin.H{},
+			})
+			return
+		}
+		helper.ClassifyContent(user, model.Report{
+			TargetType:   model.ReportTargetComment,
+			TargetID:     reply.ID,
+			ThreadID:     reply.ThreadID,
+			TargetUserID: user.ID,
+		}, thread.Category, reply.Content)
+
+		message := "reply created"
+		if moderation == model.ModerationHeld {
+			message = "reply held for review"
+			helper.HoldForReview(model.Report{
+				TargetType:   model.ReportTargetComment,
+				TargetID:     reply.ID,
+				ThreadID:     reply.ThreadID,
+				TargetUserID: user.ID,
+			}, checks)
+		} else {
+			if err := model.NotifyComment(db, thread, reply); err != nil {
+				logrus.Println(err)
+			}
+		}
+		// The stream is public, replies on a held or hidden thread stay off it
+		if moderation == model.ModerationVisible && thread.Moderation == model.ModerationVisible {
+			stream.Publish(stream.CommentCreated, thread.ID, thread.Category, gin.H{
+				"id":           reply.ID,
+				"thread_id":    reply.ThreadID,
+				"parent_id":    reply.ParentID,
+				"content":      reply.Content,
+				"content_html": reply.ContentHTML,
+				"createdAt":    reply.CreatedAt.Format(time.RFC3339),
+				"owner": gin.H{
+					"id":     user.ID,
+					"name":   user.Name,
+					"avatar": user.Avatar,
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+			"data": gin.H{
+				"comment": gin.H{
+					"id":           reply.ID,
+					"content":      reply.Content,
+					"content_html": reply.ContentHTML,
+					"createdAt":    reply.CreatedAt.Format(time.RFC3339),
+					"moderation":   reply.Moderation,
+					"parentId":     parent.ID,
+					"depth":        reply.Depth,
+					"owner": gin.H{
+						"id":     user.ID,
+						"name":   user.Name,
+						"email":  user.Email,
+						"avatar": user.Avatar,
+					},
+				},
+			},
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/audit"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PUT_THREADS_ID_LOCK_HANDLER locks a thread against new comments and votes:
+// PUT locks, DELETE unlocks. Moderators only.
+func PUT_THREADS_ID_LOCK_HANDLER(db *gorm.DB, locked bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		message := "thread locked"
+		if !locked {
+			message = "thread unlocked"
+		}
+		setThreadFlags(c, db, map[string]any{"locked": locked}, message)
+	}
+}
+
+// PUT_THREADS_ID_PIN_HANDLER pins a thread to the top of GET /threads:
+// PUT pins, DELETE unpins. ?scope=category pins it only in its category
+// listing, the default scope is global. DELETE without scope removes both pins.
+func PUT_THREADS_ID_PIN_HANDLER(db *gorm.DB, pinned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		columns := map[string]any{}
+		switch c.Query("scope") {
+		case "":
+			columns["pinned"] = pinned
+			if !pinned {
+				columns["pinned_in_category"] = false
+			}
+		case "global":
+			columns["pinned"] = pinned
+		case "category":
+			columns["pinned_in_category"] = pinned
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid scope",
+				"message": "scope must be global or category",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		message := "thread pinned"
+		if !pinned {
+			message = "thread unpinned"
+		}
+		setThreadFlags(c, db, columns, message)
+	}
+}
+
+// PUT_THREADS_ID_FEATURE_HANDLER features a thread until {"until": RFC3339},
+// model.DefaultFeaturedFor from now without a body: PUT features, DELETE
+// stops featuring it
+func PUT_THREADS_ID_FEATURE_HANDLER(db *gorm.DB, featured bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !featured {
+			setThreadFlags(c, db, map[string]any{"featured_until": nil}, "thread no longer featured")
+			return
+		}
+
+		var req struct {
+			Until *time.Time `json:"until"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": err.Error(),
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+		until := time.Now().Add(model.DefaultFeaturedFor)
+		if req.Until != nil {
+			if !req.Until.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   "invalid until",
+					"message": "until must be in the future",
+					"data":    gin.H{},
+				})
+				return
+			}
+			until = *req.Until
+		}
+		setThreadFlags(c, db, map[string]any{"featured_until": until}, "thread featured")
+	}
+}
+
+// setThreadFlags writes the moderator flags in columns to the thread of the
+// request and records the change in the audit log
+func setThreadFlags(c *gin.Context, db *gorm.DB, columns map[string]any, message string) {
+	moderator, ok := getModerator(c)
+	if !ok {
+		return
+	}
+
+	var thread model.Thread
+	if err := db.Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "thread not found",
+			"data":    gin.H{},
+		})
+		return
+	}
+	entry := audit.Update("threads", thread.ID).Before(threadFlags(thread))
+
+	// UpdateColumns leaves updated_at alone, flags are not edits
+	if err := db.Model(&model.Thread{}).Where("id = ?", thread.ID).UpdateColumns(columns).Error; err != nil {
+		audit.Log(c, db, moderator.ID, entry.Failed(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to update thread",
+			"data":    gin.H{},
+		})
+		return
+	}
+	var updated model.Thread
+	db.Where("id = ?", thread.ID).First(&updated)
+	audit.Log(c, db, moderator.ID, entry.After(threadFlags(updated)).Success(message))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data":    threadFlags(updated),
+	})
+}
+
+func threadFlags(thread model.Thread) gin.H {
+	return gin.H{
+		"id":                 thread.ID,
+		"locked":             thread.Locked,
+		"pinned":             thread.Pinned,
+		"pinned_in_category": thread.PinnedInCategory,
+		"featured":           thread.Featured,
+		"featured_until":     thread.FeaturedUntil,
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST_THREADS_ID_REPORT_HANDLER reports a thread to the moderators with a
+// reason code from model.ReportReasons and an optional note
+func POST_THREADS_ID_REPORT_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := helper.GetPostingUser(c)
+		if !ok {
+			return
+		}
+
+		var thread model.Thread
+		if err := db.Select("id", "user_id").Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		fileReport(c, db, user, model.Report{
+			TargetType:   model.ReportTargetThread,
+			TargetID:     thread.ID,
+			ThreadID:     thread.ID,
+			TargetUserID: thread.UserID,
+		})
+	}
+}
+
+// POST_COMMENTS_ID_REPORT_HANDLER reports a comment to the moderators
+func POST_COMMENTS_ID_REPORT_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := helper.GetPostingUser(c)
+		if !ok {
+			return
+		}
+
+		var comment model.Comment
+		if err := db.Select("id", "thread_id", "user_id").Where("id = ?", c.Param("commentId")).First(&comment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		fileReport(c, db, user, model.Report{
+			TargetType:   model.ReportTargetComment,
+			TargetID:     comment.ID,
+			ThreadID:     comment.ThreadID,
+			TargetUserID: comment.UserID,
+		})
+	}
+}
+
+func fileReport(c *gin.Context, db *gorm.DB, user *model.User, target model.Report) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+		Note   string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": err.Error(),
+			"data":    gin.H{},
+		})
+		return
+	}
+	req.Reason = strings.ToLower(strings.TrimSpace(req.Reason))
+	if !slices.Contains(model.ReportReasons, req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid reason",
+			"message": "reason must be one of " + strings.Join(model.ReportReasons, ", "),
+			"data":    gin.H{},
+		})
+		return
+	}
+	if target.TargetUserID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "own content",
+			"message": "you cannot report your own content",
+			"data":    gin.H{},
+		})
+		return
+	}
+
+	report, duplicate, err := model.FileReport(db, target, user.ID, req.Reason, strings.TrimSpace(req.Note))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to report",
+			"data":    gin.H{},
+		})
+		return
+	}
+
+	message := "reported, thank you"
+	if duplicate {
+		message = "you already reported this"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"report_id": report.ID,
+			"duplicate": duplicate,
+		},
+	})
+}
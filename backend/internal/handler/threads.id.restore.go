@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/stream"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST_THREADS_ID_RESTORE_HANDLER restores a deleted thread with the comments
+// deleted along with it. Owners can restore within model.RestoreGracePeriod,
+// moderators until the trash is purged.
+func POST_THREADS_ID_RESTORE_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var thread model.Thread
+		if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "deleted thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if !model.CanRestore(*user, thread.UserID, thread.Moderation, thread.DeletedAt) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "not authorized to restore this thread",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := model.RestoreThread(db, thread); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to restore thread",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		stream.Publish(stream.ThreadCreated, thread.ID, thread.Category, gin.H{
+			"id":       thread.ID,
+			"title":    thread.Title,
+			"category": thread.Category,
+			"restored": true,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "thread restored",
+			"data": gin.H{
+				"id": thread.ID,
+			},
+		})
+	}
+}
+
+// POST_THREADS_ID_COMMENTS_ID_RESTORE_HANDLER restores a deleted comment with
+// the replies deleted along with it
+func POST_THREADS_ID_COMMENTS_ID_RESTORE_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var comment model.Comment
+		if err := db.Unscoped().
+			Where("id = ? AND thread_id = ? AND deleted_at IS NOT NULL", c.Param("commentId"), c.Param("threadId")).
+			First(&comment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "deleted comment not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if !model.CanRestore(*user, comment.UserID, comment.Moderation, comment.DeletedAt) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "not authorized to restore this comment",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		// A comment can only come back under a live thread and parent
+		var live int64
+		db.Model(&model.Thread{}).Where("id = ?", comment.ThreadID).Count(&live)
+		if live > 0 && comment.ParentID != nil && *comment.ParentID != "" {
+			db.Model(&model.Comment{}).Where("id = ?", *comment.ParentID).Count(&live)
+		}
+		if live == 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "parent deleted",
+				"message": "the thread or parent comment is deleted, restore it instead",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := model.RestoreComment(db, comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to restore comment",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		stream.PublishForThread(db, stream.CommentCreated, comment.ThreadID, gin.H{
+			"id":           comment.ID,
+			"thread_id":    comment.ThreadID,
+			"parent_id":    comment.ParentID,
+			"content":      comment.Content,
+			"content_html": comment.ContentHTML,
+			"restored":     true,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "comment restored",
+			"data": gin.H{
+				"id": comment.ID,
+			},
+		})
+	}
+}
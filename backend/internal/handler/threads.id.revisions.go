@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_THREADS_ID_REVISIONS_HANDLER lists every version of a thread, oldest
+// first. Only the author and moderators can see the edit history.
+func GET_THREADS_ID_REVISIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if revisions, ok := findRevisions(c, db, model.RevisionSourceThread); ok {
+			revisionsResponse(c, revisions)
+		}
+	}
+}
+
+// GET_THREADS_ID_REVISIONS_DIFF_HANDLER returns the unified diff between two
+// versions of a thread, ?from= and ?to= default to the last edit
+func GET_THREADS_ID_REVISIONS_DIFF_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if revisions, ok := findRevisions(c, db, model.RevisionSourceThread); ok {
+			revisionsDiffResponse(c, revisions)
+		}
+	}
+}
+
+// GET_THREADS_ID_COMMENTS_ID_REVISIONS_HANDLER lists every version of a
+// comment, oldest first
+func GET_THREADS_ID_COMMENTS_ID_REVISIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if revisions, ok := findRevisions(c, db, model.RevisionSourceComment); ok {
+			revisionsResponse(c, revisions)
+		}
+	}
+}
+
+// GET_THREADS_ID_COMMENTS_ID_REVISIONS_DIFF_HANDLER returns the unified diff
+// between two versions of a comment
+func GET_THREADS_ID_COMMENTS_ID_REVISIONS_DIFF_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if revisions, ok := findRevisions(c, db, model.RevisionSourceComment); ok {
+			revisionsDiffResponse(c, revisions)
+		}
+	}
+}
+
+// findRevisions loads the revisions of the thread or comment in the URL after
+// checking the current user may see them. It writes the error response and
+// returns false when not.
+func findRevisions(c *gin.Context, db *gorm.DB, sourceType string) ([]model.Revision, bool) {
+	user, err := helper.GetFirebaseUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, false
+	}
+
+	var current model.Revision
+	if sourceType == model.RevisionSourceComment {
+		var comment model.Comment
+		if err := db.Where("id = ? AND thread_id = ?", c.Param("commentId"), c.Param("threadId")).First(&comment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "comment not found",
+				"data":    gin.H{},
+			})
+			return nil, false
+		}
+		current = model.CommentRevision(comment, comment.UserID, comment.CreatedAt)
+	} else {
+		var thread model.Thread
+		if err := db.Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return nil, false
+		}
+		current = model.ThreadRevision(thread, thread.UserID, thread.CreatedAt)
+	}
+
+	if current.EditorID != user.ID && !user.IsModerator() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Forbidden",
+			"message": "not authorized to view the edit history",
+			"data":    gin.H{},
+		})
+		return nil, false
+	}
+
+	revisions, err := model.ListRevisions(db, current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to get revisions",
+			"data":    gin.H{},
+		})
+		return nil, false
+	}
+	return revisions, true
+}
+
+func revisionsResponse(c *gin.Context, revisions []model.Revision) {
+	data := make([]gin.H, 0, len(revisions))
+	for _, r := range revisions {
+		item := gin.H{
+			"version": r.Version,
+			"current": r.Version == len(revisions),
+			"editor": gin.H{
+				"id":     r.Editor.ID,
+				"name":   r.Editor.Name,
+				"avatar": r.Editor.Avatar,
+			},
+			"created_at": r.CreatedAt,
+		}
+		for _, field := range r.Fields() {
+			item[field[0]] = field[1]
+		}
+		data = append(data, item)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"edit_count": len(revisions) - 1,
+		"data":       data,
+	})
+}
+
+func revisionsDiffResponse(c *gin.Context, revisions []model.Revision) {
+	latest := len(revisions)
+	to, err := revisionParam(c, "to", latest, latest)
+	var from int
+	if err == nil {
+		from, err = revisionParam(c, "from", max(to-1, 1), latest)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": err.Error(),
+			"data":    gin.H{},
+		})
+		return
+	}
+
+	a, b := revisions[from-1].Fields(), revisions[to-1].Fields()
+	var diff strings.Builder
+	for i := range a {
+		diff.WriteString(util.UnifiedDiff(
+			fmt.Sprintf("v%d/%s", from, a[i][0]),
+			fmt.Sprintf("v%d/%s", to, b[i][0]),
+			a[i][1], b[i][1]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from": from,
+			"to":   to,
+			"diff": diff.String(),
+		},
+	})
+}
+
+// revisionParam reads a version number query parameter, 1..latest
+func revisionParam(c *gin.Context, name string, fallback, latest int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 || v > latest {
+		return 0, fmt.Errorf("%s must be a version between 1 and %d", name, latest)
+	}
+	return v, nil
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/middleware"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/summary"
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrSummaryUnavailable is the error code of summaries the LLM could not write
+const ErrSummaryUnavailable = "SUMMARY_UNAVAILABLE"
+
+// summaryLocks has one mutex per thread while its summary is being written,
+// so a stale summary is written once however many readers ask for it at the
+// same time. The others do not wait, they get the last summary.
+var summaryLocks sync.Map
+
+// GET_THREADS_ID_SUMMARY_HANDLER returns the TL;DR of a thread, written by
+// the LLM of LLM_PROVIDER. The summary is cached until
+// summary.RefreshAfter new comments arrive or the thread is edited.
+// Writing one, also with ?refresh=true for signed in users, counts against
+// the regenerate policy: over it the stale summary is returned as is.
+func GET_THREADS_ID_SUMMARY_HANDLER(db *gorm.DB, regenerate middleware.Policy) gin.HandlerFunc {
+	regenerate = regenerate.FromEnv()
+	return func(c *gin.Context) {
+		viewer, _ := helper.GetFirebaseUser(c)
+		var thread model.Thread
+		if err := db.Scopes(model.Visible("threads", viewer)).
+			Select("id", "title", "body", "category", "edit_count").
+			Where("id = ?", c.Param("threadId")).
+			First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		refresh := c.Query("refresh") == "true"
+		if refresh && viewer == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Unauthorized",
+				"message": "sign in to refresh the summary",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		// The summary is shared by every reader, it only covers what
+		// anonymous readers see
+		var comments int64
+		db.Model(&model.Comment{}).Scopes(model.Visible("comments", nil)).Where("thread_id = ?", thread.ID).Count(&comments)
+
+		cached, found := cachedSummary(db, thread.ID)
+		if found && !refresh && !summaryStale(cached, thread, comments) {
+			summaryResponse(c, cached, comments, "")
+			return
+		}
+
+		value, _ := summaryLocks.LoadOrStore(thread.ID, &sync.Mutex{})
+		lock := value.(*sync.Mutex)
+		if !lock.TryLock() {
+			if found {
+				summaryResponse(c, cached, comments, "summary is being refreshed, showing the last summary")
+				return
+			}
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"error":   ErrSummaryUnavailable,
+				"message": "the summary is being written, try again in a few seconds",
+				"data":    gin.H{},
+			})
+			return
+		}
+		defer func() {
+			summaryLocks.CompareAndDelete(thread.ID, lock)
+			lock.Unlock()
+		}()
+		// Someone else may have written it since we read it
+		if again, ok := cachedSummary(db, thread.ID); ok && !summaryStale(again, thread, comments) && (!refresh || again.CreatedAt.After(cached.CreatedAt)) {
+			summaryResponse(c, again, comments, "")
+			return
+		}
+
+		if limit, retry, ok := regenerate.Take(c); !ok {
+			if found {
+				summaryResponse(c, cached, comments, "summary quota reached, showing the last summary")
+				return
+			}
+			regenerate.Reject(c, limit, retry)
+			return
+		}
+
+		written, err := writeSummary(c, db, thread, comments)
+		if err != nil {
+			logrus.Errorf("summary of thread %s: %v", thread.ID, err)
+			if found {
+				summaryResponse(c, cached, comments, "summary could not be refreshed, showing the last summary")
+				return
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"error":   ErrSummaryUnavailable,
+				"message": "the summary could not be written, try again later",
+				"data":    gin.H{},
+			})
+			return
+		}
+		summaryResponse(c, written, comments, "")
+	}
+}
+
+func cachedSummary(db *gorm.DB, threadID string) (model.ThreadSummary, bool) {
+	var cached model.ThreadSummary
+	found := db.Where("thread_id = ?", threadID).Limit(1).Find(&cached)
+	return cached, found.Error == nil && found.RowsAffected > 0
+}
+
+func summaryStale(cached model.ThreadSummary, thread model.Thread, comments int64) bool {
+	return int(comments)-cached.CommentCount >= summary.RefreshAfter() || cached.EditCount != thread.EditCount
+}
+
+// writeSummary has the LLM summarize the thread and its best comments and
+// caches the result
+func writeSummary(c *gin.Context, db *gorm.DB, thread model.Thread, comments int64) (model.ThreadSummary, error) {
+	llm, provider, err := llmClient.Model()
+	if err != nil {
+		return model.ThreadSummary{}, err
+	}
+
+	var best []model.Comment
+	if err := db.Scopes(model.Visible("comments", nil)).
+		Preload("User").
+		Where("thread_id = ?", thread.ID).
+		Order("total_up_votes - total_down_votes desc, created_at asc").
+		Limit(200).
+		Find(&best).Error; err != nil {
+		return model.ThreadSummary{}, err
+	}
+	input := summary.Thread{Title: thread.Title, Body: thread.Body, Category: thread.Category}
+	for _, comment := range best {
+		input.Comments = append(input.Comments, summary.Comment{
+			Author: comment.User.Name,
+			Score:  comment.TotalUpVotes - comment.TotalDownVotes,
+			Body:   comment.Content,
+		})
+	}
+	prompt, included, tokens := summary.Prompt(input, summary.Budget())
+
+	text, err := summary.Summarize(c.Request.Context(), llm, prompt)
+	if err != nil {
+		return model.ThreadSummary{}, err
+	}
+	written := model.ThreadSummary{
+		ThreadID:         thread.ID,
+		Summary:          text,
+		Provider:         provider,
+		CommentCount:     int(comments),
+		CommentsIncluded: included,
+		EditCount:        thread.EditCount,
+		PromptTokens:     tokens,
+		GeneratedByID:    "ip:" + c.ClientIP(),
+		CreatedAt:        time.Now(),
+	}
+	if user, err := helper.GetFirebaseUser(c); err == nil {
+		written.GeneratedByID = user.ID
+	}
+	return written, db.Save(&written).Error
+}
+
+func summaryResponse(c *gin.Context, s model.ThreadSummary, comments int64, message string) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"thread_id":         s.ThreadID,
+			"summary":           s.Summary,
+			"provider":          s.Provider,
+			"comment_count":     s.CommentCount,
+			"comments_included": s.CommentsIncluded,
+			"new_comments":      max(int(comments)-s.CommentCount, 0),
+			"stale":             message != "",
+			"created_at":        s.CreatedAt,
+		},
+	})
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type voteRequest struct {
+	Value *int `json:"value" binding:"required,oneof=1 0 -1"`
+}
+
+// PUT_THREADS_ID_VOTE_HANDLER sets the current user's vote on a thread,
+// {"value": 1|0|-1}. Sending the same value again changes nothing.
+func PUT_THREADS_ID_VOTE_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := bindVote(c); ok {
+			voteThread(c, db, value)
+		}
+	}
+}
+
+// POST_THREADS_ID_VOTE_HANDLER is PUT_THREADS_ID_VOTE_HANDLER with a fixed
+// value, for the older up-vote, down-vote and neutral-vote routes
+func POST_THREADS_ID_VOTE_HANDLER(db *gorm.DB, value int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		voteThread(c, db, value)
+	}
+}
+
+// PUT_COMMENTS_ID_VOTE_HANDLER is PUT_THREADS_ID_VOTE_HANDLER for a comment
+func PUT_COMMENTS_ID_VOTE_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := bindVote(c); ok {
+			voteComment(c, db, value)
+		}
+	}
+}
+
+// POST_THREADS_ID_COMMENTS_ID_VOTE_HANDLER is PUT_COMMENTS_ID_VOTE_HANDLER
+// with a fixed value, for the older comment vote routes
+func POST_THREADS_ID_COMMENTS_ID_VOTE_HANDLER(db *gorm.DB, value int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		voteComment(c, db, value)
+	}
+}
+
+func bindVote(c *gin.Context) (int, bool) {
+	var req voteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "value must be 1, 0 or -1",
+			"data":    gin.H{},
+		})
+		return 0, false
+	}
+	return *req.Value, true
+}
+
+func voteThread(c *gin.Context, db *gorm.DB, value int) {
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
+		return
+	}
+
+	var thread model.Thread
+	if err := db.Scopes(model.Visible("threads", user)).Select("id").Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "thread not found",
+			"data":    gin.H{},
+		})
+		return
+	}
+	if !helper.CheckThreadOpen(c, thread.ID, user) {
+		return
+	}
+
+	vote, err := model.CastVote(db, model.VoteThread, thread.ID, user.ID, value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to save vote",
+			"data":    gin.H{},
+		})
+		return
+	}
+	if err := db.Where("id = ?", thread.ID).First(&thread).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "thread not found",
+			"data":    gin.H{},
+		})
+		return
+	}
+
+	if vote.Previous != vote.VoteType {
+		if vote.VoteType == "up" {
+			if err := model.Notify(db, thread.UserID, user.ID, model.NotifyThreadUpvote, thread.ID, nil); err != nil {
+				logrus.Println(err)
+			}
+		}
+		stream.Publish(stream.ThreadVoted, thread.ID, thread.Category, gin.H{
+			"thread_id":        thread.ID,
+			"total_up_votes":   thread.TotalUpVotes,
+			"total_down_votes": thread.TotalDownVotes,
+			"score":            thread.Score,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "thread " + vote.VoteType + " voted",
+		"data": gin.H{
+			"id":               vote.ID,
+			"thread_id":        thread.ID,
+			"user_id":          user.ID,
+			"value":            value,
+			"vote_type":        vote.VoteType,
+			"total_up_votes":   thread.TotalUpVotes,
+			"total_down_votes": thread.TotalDownVotes,
+			"total_comments":   thread.TotalComments,
+			"up_voted_by_me":   vote.VoteType == "up",
+			"down_voted_by_me": vote.VoteType == "down",
+		},
+	})
+}
+
+// voteComment votes on :commentId, which must belong to :threadId on the
+// routes that have one
+func voteComment(c *gin.Context, db *gorm.DB, value int) {
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
+		return
+	}
+
+	query := db.Scopes(model.Visible("comments", user)).Select("id", "thread_id").Where("id = ?", c.Param("commentId"))
+	if threadID := c.Param("threadId"); threadID != "" {
+		query = query.Where("thread_id = ?", threadID)
+	}
+	var comment model.Comment
+	err := query.First(&comment).Error
+	if err == nil {
+		// Comments of a hidden or held thread are as hidden as the thread
+		err = db.Scopes(model.Visible("threads", user)).Select("id").Where("id = ?", comment.ThreadID).First(&model.Thread{}).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "comment not found",
+			"data":    gin.H{},
+		})
+		return
+	}
+	if !helper.CheckThreadOpen(c, comment.ThreadID, user) {
+		return
+	}
+
+	vote, err := model.CastVote(db, model.VoteComment, comment.ID, user.ID, value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "failed to save vote",
+			"data":    gin.H{},
+		})
+		return
+	}
+	if err := db.Where("id = ?", comment.ID).First(&comment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "comment not found",
+			"data":    gin.H{},
+		})
+		return
+	}
+
+	if vote.Previous != vote.VoteType {
+		if vote.VoteType == "up" {
+			if err := model.Notify(db, comment.UserID, user.ID, model.NotifyCommentUpvote, comment.ThreadID, &comment.ID); err != nil {
+				logrus.Println(err)
+			}
+		}
+		stream.PublishForThread(db, stream.CommentVoted, comment.ThreadID, gin.H{
+			"id":               comment.ID,
+			"thread_id":        comment.ThreadID,
+			"total_up_votes":   comment.TotalUpVotes,
+			"total_down_votes": comment.TotalDownVotes,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "thread comment " + vote.VoteType + " voted",
+		"data": gin.H{
+			"id":               vote.ID,
+			"comment_id":       comment.ID,
+			"thread_id":        comment.ThreadID,
+			"user_id":          user.ID,
+			"value":            value,
+			"vote_type":        vote.VoteType,
+			"total_up_votes":   comment.TotalUpVotes,
+			"total_down_votes": comment.TotalDownVotes,
+			"up_voted_by_me":   vote.VoteType == "up",
+			"down_voted_by_me": vote.VoteType == "down",
+		},
+	})
+}
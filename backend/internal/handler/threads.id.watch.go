@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PUT_THREADS_ID_WATCH_HANDLER sets whether the current user gets notified
+// about new comments on a thread: PUT watches, DELETE unwatches
+func PUT_THREADS_ID_WATCH_HANDLER(db *gorm.DB, watching bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		var thread model.Thread
+		if err := db.Scopes(model.Visible("threads", user)).Select("id", "user_id").Where("id = ?", c.Param("threadId")).First(&thread).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "thread not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		watch := model.ThreadWatch{
+			UserID:    user.ID,
+			ThreadID:  thread.ID,
+			Watching:  watching,
+			UpdatedAt: time.Now(),
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "thread_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"watching", "updated_at"}),
+		}).Create(&watch).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to update watch",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		message := "thread watched"
+		if !watching {
+			message = "thread unwatched"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+			"data": gin.H{
+				"thread_id": thread.ID,
+				"watching":  watching,
+			},
+		})
+	}
+}
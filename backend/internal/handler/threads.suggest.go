@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/search"
+	"microblog/backend/internal/suggest"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST_THREADS_SUGGEST_HANDLER suggests categories, a title and possible
+// duplicate threads for a draft, {"title": "...", "body": "..."}. See
+// suggest.Suggest, the suggestions come from keywords when no LLM is set.
+func POST_THREADS_SUGGEST_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "sign in to get suggestions",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var req struct {
+			Title string `json:"title"`
+			Body  string `json:"body" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+		draft := suggest.Draft{Title: req.Title, Body: req.Body}
+
+		var categories []model.Category
+		if err := db.Where("archived = ?", false).Order("position asc, name asc").Find(&categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to get categories",
+				"data":    gin.H{},
+			})
+			return
+		}
+		// What each category is used for, from the titles of its recent threads
+		var recent []model.Thread
+		db.Scopes(model.Visible("threads", nil)).
+			Select("category", "title").
+			Order("created_at desc").
+			Limit(500).
+			Find(&recent)
+		titles := map[string][]string{}
+		for _, t := range recent {
+			if len(titles[t.Category]) < 30 {
+				titles[t.Category] = append(titles[t.Category], t.Title)
+			}
+		}
+		choices := make([]suggest.Category, 0, len(categories))
+		for _, category := range categories {
+			choices = append(choices, suggest.Category{
+				Slug:        category.Slug,
+				Name:        category.Name,
+				Description: category.Description,
+				Titles:      titles[category.Slug],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    suggest.Suggest(c.Request.Context(), draft, choices, duplicateCandidates(db, user, draft)),
+		})
+	}
+}
+
+// duplicateCandidates searches the threads user can see for the draft's top
+// keywords, dropping the least frequent keyword until enough threads match
+func duplicateCandidates(db *gorm.DB, user *model.User, draft suggest.Draft) []suggest.Thread {
+	const want = 20
+	keywords := suggest.Keywords(draft.Title, draft.Body, 3)
+	seen := map[string]bool{}
+	candidates := []suggest.Thread{}
+	for k := len(keywords); k > 0 && len(candidates) < want; k-- {
+		var threads []model.Thread
+		query := db.Model(&model.Thread{}).Scopes(model.Visible("threads", user))
+		if err := search.Apply(db, query, strings.Join(keywords[:k], " ")).
+			Select("threads.id", "threads.title", "threads.body").
+			Order(search.Alias + ".search_rank desc").
+			Limit(want).
+			Find(&threads).Error; err != nil {
+			break
+		}
+		for _, t := range threads {
+			if !seen[t.ID] && len(candidates) < want {
+				seen[t.ID] = true
+				candidates = append(candidates, suggest.Thread{ID: t.ID, Title: t.Title, Body: t.Body})
+			}
+		}
+	}
+	return candidates
+}
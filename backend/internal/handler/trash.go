@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_TRASH_HANDLER lists deleted threads (?type=threads, the default) or
+// comments (?type=comments) for moderators, most recently deleted first, with
+// when each one is purged. start/length page like the DataTables lists.
+func GET_TRASH_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		if !user.IsModerator() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden",
+				"message": "only moderators can see the trash",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		var req struct {
+			Type   string `form:"type"`
+			Start  int    `form:"start"`
+			Length int    `form:"length"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 100 {
+			req.Length = 100
+		}
+
+		var recordsTotal int64
+		data := []gin.H{}
+		switch req.Type {
+		case "", "threads":
+			query := db.Unscoped().Model(&model.Thread{}).Where("deleted_at IS NOT NULL")
+			query.Count(&recordsTotal)
+			var threads []model.Thread
+			if err := query.Preload("User").
+				Order("deleted_at desc").
+				Offset(req.Start).
+				Limit(req.Length).
+				Find(&threads).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "failed to get trash",
+				})
+				return
+			}
+			for _, t := range threads {
+				data = append(data, gin.H{
+					"id":             t.ID,
+					"title":          t.Title,
+					"category":       t.Category,
+					"total_comments": t.TotalComments,
+					"user":           trashUser(t.User),
+					"created_at":     t.CreatedAt,
+					"deleted_at":     t.DeletedAt,
+					"purge_at":       model.PurgeAt(t.DeletedAt),
+				})
+			}
+		case "comments":
+			// Comments deleted together with their thread are listed with the thread
+			query := db.Unscoped().Model(&model.Comment{}).
+				Where("comments.deleted_at IS NOT NULL").
+				Where("NOT EXISTS (?)", db.Unscoped().Model(&model.Thread{}).
+					Select("1").
+					Where("threads.id = comments.thread_id AND threads.deleted_at = comments.deleted_at"))
+			query.Count(&recordsTotal)
+			var comments []model.Comment
+			if err := query.Preload("User").
+				Order("comments.deleted_at desc").
+				Offset(req.Start).
+				Limit(req.Length).
+				Find(&comments).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "failed to get trash",
+				})
+				return
+			}
+			for _, cm := range comments {
+				data = append(data, gin.H{
+					"id":         cm.ID,
+					"thread_id":  cm.ThreadID,
+					"parent_id":  cm.ParentID,
+					"content":    cm.Content,
+					"user":       trashUser(cm.User),
+					"created_at": cm.CreatedAt,
+					"deleted_at": cm.DeletedAt,
+					"purge_at":   model.PurgeAt(cm.DeletedAt),
+				})
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "invalid type",
+				"message": "type must be threads or comments",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"recordsTotal": recordsTotal,
+			"data":         data,
+		})
+	}
+}
+
+func trashUser(u model.User) gin.H {
+	return gin.H{
+		"id":     u.ID,
+		"name":   u.Name,
+		"avatar": u.Avatar,
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_USERS_ID_REPUTATION_HANDLER is a user's karma with its ledger, newest
+// event first. The user goes by id or username, gin wants the same
+// wildcard name as the other /users/:username routes. Reversals show as
+// their own events with reverses_id set. ?reason= filters, start/length
+// page like the DataTables lists. Moderation penalties are only listed to the
+// user and moderators, banned users show as a placeholder without a ledger.
+func GET_USERS_ID_REPUTATION_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user model.User
+		if db.Where("id = ?", c.Param("username")).Limit(1).Find(&user).RowsAffected == 0 {
+			var err error
+			if user, err = model.FindUserByUsername(db, c.Param("username")); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "user not found",
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+
+		var req struct {
+			Reason string `form:"reason"`
+			Start  int    `form:"start"`
+			Length int    `form:"length"`
+		}
+		_ = c.BindQuery(&req)
+		if req.Length <= 0 {
+			req.Length = 20
+		}
+		if req.Length > 100 {
+			req.Length = 100
+		}
+
+		events := []model.ReputationEvent{}
+		var recordsTotal int64
+		if user.Status != model.StatusBanned {
+			query := db.Model(&model.ReputationEvent{}).Where("user_id = ?", user.ID)
+			if viewer, _ := helper.GetFirebaseUser(c); viewer == nil || (viewer.ID != user.ID && !viewer.IsModerator()) {
+				query = query.Where("reason NOT IN ?", model.RepPenalties)
+			}
+			if req.Reason != "" {
+				query = query.Where("reason = ?", req.Reason)
+			}
+			query.Count(&recordsTotal)
+			if err := query.Order("created_at desc, id").
+				Offset(req.Start).
+				Limit(req.Length).
+				Find(&events).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "failed to get reputation",
+					"data":    gin.H{},
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"data":         events,
+			"user":         publicUser(&user),
+			"recordsTotal": recordsTotal,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_USERS_MENTIONS_HANDLER is the @mention typeahead: users whose username
+// starts with ?q= (case-insensitive), shortest usernames first
+func GET_USERS_MENTIONS_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Query("q")), "@"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if limit <= 0 || limit > 50 {
+			limit = 10
+		}
+
+		// Only characters a username can have, so _ is the one LIKE wildcard
+		// left to escape. It is escaped with ! because MySQL string literals
+		// treat \ as an escape of their own.
+		for _, r := range q {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+				c.JSON(http.StatusOK, gin.H{
+					"success": true,
+					"data":    []gin.H{},
+				})
+				return
+			}
+		}
+
+		length := "LENGTH"
+		if db.Dialector.Name() == "sqlserver" {
+			length = "LEN"
+		}
+		var users []model.User
+		if err := db.Select("id", "username", "name", "avatar").
+			Where("username <> ''").
+			Where("LOWER(username) LIKE ? ESCAPE '!'", strings.ReplaceAll(q, "_", "!_")+"%").
+			Order(length + "(username), username").
+			Limit(limit).
+			Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to search users",
+			})
+			return
+		}
+
+		data := make([]gin.H, 0, len(users))
+		for _, u := range users {
+			data = append(data, gin.H{
+				"id":       u.ID,
+				"username": u.Username,
+				"name":     u.Name,
+				"avatar":   u.Avatar,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    data,
+		})
+	}
+}
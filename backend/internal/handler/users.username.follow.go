@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PUT_USERS_USERNAME_FOLLOW_HANDLER makes the current user follow another
+// user: PUT follows, DELETE unfollows
+func PUT_USERS_USERNAME_FOLLOW_HANDLER(db *gorm.DB, following bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helper.GetFirebaseUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		target, err := model.FindUserByUsername(db, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "user not found",
+				"data":    gin.H{},
+			})
+			return
+		}
+		if target.ID == user.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "own account",
+				"message": "you cannot follow yourself",
+				"data":    gin.H{},
+			})
+			return
+		}
+
+		if err := model.SetFollow(db, user.ID, model.FollowUser, target.ID, following); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "failed to update follow",
+				"data":    gin.H{},
+			})
+			return
+		}
+		db.Select("follower_count").Where("id = ?", target.ID).First(&target)
+
+		message := "user followed"
+		if !following {
+			message = "user unfollowed"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+			"data": gin.H{
+				"user_id":        target.ID,
+				"username":       target.Username,
+				"following":      following,
+				"follower_count": target.FollowerCount,
+			},
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET_USERS_USERNAME_HANDLER is the public profile of a user: no email,
+// status or role details, just what other members may see, with activity
+// stats. Banned users show as a placeholder.
+func GET_USERS_USERNAME_HANDLER(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := model.FindUserByUsername(db, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": "user not found",
+				"data":    publicUser(nil),
+			})
+			return
+		}
+
+		profile := publicUser(&user)
+		if user.Status != model.StatusBanned {
+			stats, err := model.UserActivity(db, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"message": "failed to get user activity",
+					"data":    gin.H{},
+				})
+				return
+			}
+			profile["stats"] = stats
+			if viewer, err := helper.GetFirebaseUser(c); err == nil {
+				profile["followed_by_me"] = model.IsFollowing(db, viewer.ID, model.FollowUser, user.ID)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    profile,
+		})
+	}
+}
+
+// GET_USERS_USERNAME_THREADS_HANDLER lists a user's threads, like
+// GET_THREADS_HANDLER
+func GET_USERS_USERNAME_THREADS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return listThreads(db, preload, func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool) {
+		author, ok := profileAuthor(c, db)
+		if !ok {
+			return nil, false
+		}
+		return func(q *gorm.DB) *gorm.DB {
+			return q.Where("threads.user_id = ?", author)
+		}, true
+	})
+}
+
+// GET_USERS_USERNAME_COMMENTS_HANDLER lists a user's comments across threads,
+// newest first
+func GET_USERS_USERNAME_COMMENTS_HANDLER(db *gorm.DB, preload []string) gin.HandlerFunc {
+	return listComments(db, preload, func(c *gin.Context, viewer *model.User) (func(*gorm.DB) *gorm.DB, bool) {
+		author, ok := profileAuthor(c, db)
+		if !ok {
+			return nil, false
+		}
+		return func(q *gorm.DB) *gorm.DB {
+			return q.Where("comments.user_id = ?", author)
+		}, true
+	})
+}
+
+// profileAuthor is the user id behind :username, "" for banned users so their
+// tabs come back empty. It writes the error response and returns false when
+// there is no such user.
+func profileAuthor(c *gin.Context, db *gorm.DB) (string, bool) {
+	user, err := model.FindUserByUsername(db, c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"message": "user not found",
+			"data":    []gin.H{},
+		})
+		return "", false
+	}
+	if user.Status == model.StatusBanned {
+		return "", true
+	}
+	return user.ID, true
+}
+
+// publicUser is the public projection of user, a placeholder for banned and
+// deleted (nil) users
+func publicUser(user *model.User) gin.H {
+	if user == nil || user.Status == model.StatusBanned {
+		name := "[deleted]"
+		if user != nil {
+			name = "[banned]"
+		}
+		return gin.H{
+			"id":          nil,
+			"username":    name,
+			"name":        name,
+			"avatar":      "",
+			"placeholder": true,
+		}
+	}
+	return gin.H{
+		"id":              user.ID,
+		"username":        user.Username,
+		"name":            user.Name,
+		"avatar":          user.Avatar,
+		"moderator":       user.IsModerator(),
+		"joined_at":       user.CreatedAt,
+		"follower_count":  user.FollowerCount,
+		"following_count": user.FollowingCount,
+		"karma":           user.Karma,
+		"placeholder":     false,
+	}
+}
@@ -0,0 +1,93 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"microblog/backend/internal/classify"
+	"microblog/backend/internal/database"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/sirupsen/logrus"
+)
+
+// classifiedReasons are the report reasons the classifier flags posts with
+var classifiedReasons = map[string]string{
+	classify.Toxicity:   model.ReportOther,
+	classify.Harassment: model.ReportHarassment,
+	classify.Spam:       model.ReportSpam,
+	classify.OffTopic:   model.ReportOffTopic,
+}
+
+// ClassifyContent has the LLM classify a thread or comment by user that was
+// just saved, when LLM_MODERATION is on. It runs in the background: the
+// result is stored as a model.Classification and a post flagged in any
+// category is reported to the moderation queue. When the model cannot be
+// reached the post is left alone and the classification is stored as
+// unavailable. category is the forum category of the thread, looked up
+// when empty. Moderators are not classified.
+func ClassifyContent(user *model.User, target model.Report, category, text string) {
+	if !classify.Enabled() || user.IsModerator() {
+		return
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.Println("classification panicked:", r)
+			}
+		}()
+		classifyContent(target, category, text)
+	}()
+}
+
+func classifyContent(target model.Report, category, text string) {
+	db := database.DB
+	if category == "" {
+		db.Table("threads").Select("category").Where("id = ?", target.ThreadID).Scan(&category)
+	}
+
+	result := model.Classification{
+		SourceType: target.TargetType,
+		SourceID:   target.TargetID,
+		ThreadID:   target.ThreadID,
+		UserID:     target.TargetUserID,
+		Status:     model.ClassificationOK,
+	}
+	llm, provider, err := llmClient.Model()
+	result.Provider = provider
+	if err == nil {
+		result.Scores, err = classify.Classify(context.Background(), llm, category, text)
+	}
+	if err != nil {
+		logrus.Println("classification unavailable:", err)
+		result.Status = model.ClassificationUnavailable
+		result.Error = err.Error()
+		if len(result.Error) > 500 {
+			result.Error = result.Error[:500]
+		}
+	}
+	flagged := classify.Flagged(result.Scores)
+	result.Flagged = strings.Join(flagged, ",")
+	if err := db.Create(&result).Error; err != nil {
+		logrus.Println(err)
+	}
+	if len(flagged) == 0 {
+		return
+	}
+
+	// the report takes the reason of the most confident category
+	sort.SliceStable(flagged, func(i, j int) bool {
+		return result.Scores[flagged[i]] > result.Scores[flagged[j]]
+	})
+	var scores []string
+	for _, c := range flagged {
+		scores = append(scores, fmt.Sprintf("%s %.2f", c, result.Scores[c]))
+	}
+	note := "classifier: " + strings.Join(scores, ", ")
+	if _, _, err := model.FileReport(db, target, model.ClassifierReporter, classifiedReasons[flagged[0]], note); err != nil {
+		logrus.Println(err)
+	}
+}
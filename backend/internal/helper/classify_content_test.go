@@ -0,0 +1,112 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+
+	"microblog/backend/internal/database"
+	"microblog/backend/internal/model"
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestClassifyContent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Classification{}, &model.Report{}, &model.ReportEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	t.Setenv("LLM_MODERATION_THRESHOLD", "0.8")
+
+	tests := []struct {
+		name     string
+		answer   string
+		err      error
+		status   string
+		flagged  string
+		reported string // reason of the filed report, "" for none
+	}{
+		{
+			name:     "valid json",
+			answer:   `{"toxicity":0.1,"harassment":0,"spam":0.9,"off_topic":0.2}`,
+			status:   model.ClassificationOK,
+			flagged:  "spam",
+			reported: model.ReportSpam,
+		},
+		{
+			name:     "text around the json",
+			answer:   "Here you go: {\"toxicity\":0.95,\"harassment\":0.85,\"spam\":0,\"off_topic\":0} Done.",
+			status:   model.ClassificationOK,
+			flagged:  "toxicity,harassment",
+			reported: model.ReportOther, // toxicity is the most confident
+		},
+		{
+			name:   "clean post",
+			answer: `{"toxicity":0,"harassment":0,"spam":0.1,"off_topic":0}`,
+			status: model.ClassificationOK,
+		},
+		{
+			name:   "unreachable model",
+			err:    errors.New("dial tcp 127.0.0.1:11434: connection refused"),
+			status: model.ClassificationUnavailable,
+		},
+		{
+			name:   "unusable answer",
+			answer: "I cannot help with that.",
+			status: model.ClassificationUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient.SetModel(llmClient.Fake{Respond: func(string) (string, error) {
+				return tt.answer, tt.err
+			}}, llmClient.ProviderFake)
+			target := model.Report{
+				TargetType:   model.ReportTargetComment,
+				TargetID:     uuid.New().String(),
+				ThreadID:     uuid.New().String(),
+				TargetUserID: uuid.New().String(),
+			}
+			classifyContent(target, "general", "some post")
+
+			var result model.Classification
+			if err := db.Where("source_type = ? AND source_id = ?", target.TargetType, target.TargetID).First(&result).Error; err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.status {
+				t.Errorf("status = %q, want %q (error %q)", result.Status, tt.status, result.Error)
+			}
+			if result.Flagged != tt.flagged {
+				t.Errorf("flagged = %q, want %q", result.Flagged, tt.flagged)
+			}
+			if result.Provider != llmClient.ProviderFake {
+				t.Errorf("provider = %q", result.Provider)
+			}
+			if tt.status == model.ClassificationUnavailable && result.Error == "" {
+				t.Error("unavailable classification without its error")
+			}
+
+			var reports []model.Report
+			db.Preload("Entries").Where("target_type = ? AND target_id = ?", target.TargetType, target.TargetID).Find(&reports)
+			if tt.reported == "" {
+				if len(reports) != 0 {
+					t.Errorf("%d reports filed, want none", len(reports))
+				}
+				return
+			}
+			if len(reports) != 1 || len(reports[0].Entries) != 1 {
+				t.Fatalf("reports %+v, want one with one entry", reports)
+			}
+			entry := reports[0].Entries[0]
+			if entry.ReporterID != model.ClassifierReporter || entry.Reason != tt.reported {
+				t.Errorf("report by %q for %q, want %q for %q", entry.ReporterID, entry.Reason, model.ClassifierReporter, tt.reported)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"net/http"
+	"strings"
+
+	"microblog/backend/internal/database"
+	"microblog/backend/internal/model"
+	"microblog/backend/internal/spam"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrContentRejected is the error code of posts the spam checks reject
+const ErrContentRejected = "CONTENT_REJECTED"
+
+// CheckContent runs the spam checks on a new thread or comment by user
+// before it is saved. It writes a 422 response and returns false when the
+// post is rejected, otherwise it returns the moderation state to save the
+// post with and why it is held, if it is. Moderators skip the checks.
+func CheckContent(c *gin.Context, user *model.User, post spam.Post) (string, []spam.Result, bool) {
+	if user.IsModerator() {
+		return model.ModerationVisible, nil, true
+	}
+	post.UserID = user.ID
+	post.UserCreatedAt = user.CreatedAt
+
+	verdict, results := spam.Default().Run(database.DB, post)
+	switch verdict {
+	case spam.Reject:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   ErrContentRejected,
+			"message": "your post looks like spam: " + results[len(results)-1].Reason,
+			"data": gin.H{
+				"checks": results,
+			},
+		})
+		return "", results, false
+	case spam.Hold:
+		return model.ModerationHeld, results, true
+	}
+	return model.ModerationVisible, nil, true
+}
+
+// HoldForReview puts a post CheckContent held in the moderation queue as a
+// spam report, dismissing the report publishes the post
+func HoldForReview(target model.Report, results []spam.Result) {
+	var reasons []string
+	for _, r := range results {
+		reasons = append(reasons, r.Check+": "+r.Reason)
+	}
+	note := strings.Join(reasons, "; ")
+	if len(note) > 500 {
+		note = note[:500]
+	}
+	if _, _, err := model.FileReport(database.DB, target, model.SystemReporter, model.ReportSpam, note); err != nil {
+		logrus.Println(err)
+	}
+}
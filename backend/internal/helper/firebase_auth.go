@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"microblog/backend/internal/database"
 	"microblog/backend/internal/model"
@@ -33,7 +34,25 @@ type FirebaseAuthData struct {
 
 var superUserEmails []string
 
+// ErrAccountSuspended is the error code of writes by suspended or banned users
+const ErrAccountSuspended = "ACCOUNT_SUSPENDED"
+
 func GetFirebaseUser(c *gin.Context) (*model.User, error) {
+	// Middleware like the rate limiter may have looked the user up already
+	if user, ok := c.Get(contextUserKey); ok {
+		return user.(*model.User), nil
+	}
+	user, err := getFirebaseUser(c)
+	if err == nil {
+		c.Set(contextUserKey, user)
+	}
+	return user, err
+}
+
+// contextUserKey holds the request's user once GetFirebaseUser found it
+const contextUserKey = "helper.user"
+
+func getFirebaseUser(c *gin.Context) (*model.User, error) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		return nil, fmt.Errorf("authorization header missing")
@@ -228,3 +247,33 @@ func GetFirebaseUser2(r *http.Request) (*model.User, error) {
 	}
 	return &usrNew, nil
 }
+
+// GetPostingUser is GetFirebaseUser for endpoints that write content, it
+// also turns away suspended and banned users. It writes the error response
+// and returns false when the request cannot go on.
+func GetPostingUser(c *gin.Context) (*model.User, bool) {
+	user, err := GetFirebaseUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, false
+	}
+	if user.IsSuspended() {
+		message := "your account is suspended"
+		if user.Status == model.StatusBanned {
+			message = "your account is banned"
+		} else if user.SuspendedUntil != nil {
+			message += " until " + user.SuspendedUntil.Format(time.RFC3339)
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   ErrAccountSuspended,
+			"message": message,
+			"data":    gin.H{},
+		})
+		return nil, false
+	}
+	return user, true
+}
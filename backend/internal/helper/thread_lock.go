@@ -0,0 +1,34 @@
+package helper
+
+import (
+	"net/http"
+
+	"microblog/backend/internal/database"
+	"microblog/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrThreadLocked is the error code of comments and votes on locked threads
+const ErrThreadLocked = "THREAD_LOCKED"
+
+// CheckThreadOpen is for endpoints that comment or vote on a thread. It writes
+// a 423 response and returns false when the thread is locked, moderators can
+// still post in locked threads.
+func CheckThreadOpen(c *gin.Context, threadID string, user *model.User) bool {
+	if user.IsModerator() {
+		return true
+	}
+	var locked int64
+	database.DB.Model(&model.Thread{}).Where("id = ? AND locked = ?", threadID, true).Count(&locked)
+	if locked == 0 {
+		return true
+	}
+	c.JSON(http.StatusLocked, gin.H{
+		"success": false,
+		"error":   ErrThreadLocked,
+		"message": "the thread is locked",
+		"data":    gin.H{},
+	})
+	return false
+}
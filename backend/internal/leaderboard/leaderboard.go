@@ -0,0 +1,173 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"microblog/backend/pkg/kvstore"
+	"microblog/backend/pkg/util"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Windows are the ?window= values, "all" means no limit
+var Windows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// Size is how many users a leaderboard holds
+const Size = 100
+
+// CacheTTL is how long a computed leaderboard is served, it outlives a couple
+// of refreshes so a slow one never leaves the cache empty
+const CacheTTL = 30 * time.Minute
+
+// Weights are the points a user earns per activity
+type Weights struct {
+	Vote    int `json:"vote"`    // up or down vote cast on a thread or comment
+	Comment int `json:"comment"` // comment written
+}
+
+// DefaultWeights are read from LEADERBOARD_VOTE_WEIGHT and
+// LEADERBOARD_COMMENT_WEIGHT, 5 and 20 when unset
+func DefaultWeights() Weights {
+	return Weights{
+		Vote:    util.Getenv("LEADERBOARD_VOTE_WEIGHT", 5),
+		Comment: util.Getenv("LEADERBOARD_COMMENT_WEIGHT", 20),
+	}
+}
+
+// Entry is one user's row on a leaderboard
+type Entry struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Avatar   string `json:"avatar"`
+	Votes    int64  `json:"votes"`
+	Comments int64  `json:"comments"`
+	Score    int64  `json:"score"`
+}
+
+// Board is a computed leaderboard
+type Board struct {
+	Window      string    `json:"window"`
+	Category    string    `json:"category"` // empty for every category
+	Weights     Weights   `json:"weights"`
+	Entries     []Entry   `json:"entries"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Compute ranks users by the weighted votes they cast and comments they wrote
+// within window, on live published threads of category ("" for all), in one
+// query. Held, hidden and removed posts do not count.
+func Compute(db *gorm.DB, window, category string, weights Weights, now time.Time) (Board, error) {
+	span, ok := Windows[window]
+	if !ok {
+		return Board{}, fmt.Errorf("unknown window '%s'", window)
+	}
+	board := Board{Window: window, Category: category, Weights: weights, Entries: []Entry{}, GeneratedAt: now}
+
+	threadVotes := db.Table("thread_votes").
+		Select("thread_votes.user_id, 1 AS votes, 0 AS comments").
+		Joins("JOIN threads ON threads.id = thread_votes.thread_id").
+		Where("thread_votes.vote_type IN ? AND threads.deleted_at IS NULL AND threads.moderation = ''", []string{"up", "down"})
+	commentVotes := db.Table("comment_votes").
+		Select("comment_votes.user_id, 1 AS votes, 0 AS comments").
+		Joins("JOIN comments ON comments.id = comment_votes.comment_id").
+		Joins("JOIN threads ON threads.id = comments.thread_id").
+		Where("comment_votes.vote_type IN ? AND comments.deleted_at IS NULL AND threads.deleted_at IS NULL", []string{"up", "down"}).
+		Where("comments.moderation = '' AND threads.moderation = ''")
+	comments := db.Table("comments").
+		Select("comments.user_id, 0 AS votes, 1 AS comments").
+		Joins("JOIN threads ON threads.id = comments.thread_id").
+		Where("comments.deleted_at IS NULL AND threads.deleted_at IS NULL").
+		Where("comments.moderation = '' AND threads.moderation = ''")
+	if span > 0 {
+		since := now.Add(-span)
+		threadVotes = threadVotes.Where("thread_votes.created_at >= ?", since)
+		commentVotes = commentVotes.Where("comment_votes.created_at >= ?", since)
+		comments = comments.Where("comments.created_at >= ?", since)
+	}
+	if category != "" {
+		threadVotes = threadVotes.Where("threads.category = ?", category)
+		commentVotes = commentVotes.Where("threads.category = ?", category)
+		comments = comments.Where("threads.category = ?", category)
+	}
+
+	activity := db.Raw("? UNION ALL ? UNION ALL ?", threadVotes, commentVotes, comments)
+	err := db.Table("(?) AS activity", activity).
+		Select("users.id AS user_id, users.username, users.name, users.email, users.avatar, "+
+			"SUM(activity.votes) AS votes, SUM(activity.comments) AS comments, "+
+			"SUM(activity.votes) * ? + SUM(activity.comments) * ? AS score", weights.Vote, weights.Comment).
+		Joins("JOIN users ON users.id = activity.user_id").
+		Where("users.status <> ?", "banned").
+		Group("users.id, users.username, users.name, users.email, users.avatar").
+		Order("score desc, users.id").
+		Limit(Size).
+		Scan(&board.Entries).Error
+	return board, err
+}
+
+// Get serves a leaderboard from the cache, computing it on a miss
+func Get(db *gorm.DB, window, category string) (Board, error) {
+	if raw, err := kvstore.GetKey(cacheKey(window, category)); err == nil {
+		var board Board
+		if json.Unmarshal([]byte(raw), &board) == nil {
+			return board, nil
+		}
+	}
+	return refresh(db, window, category, DefaultWeights(), time.Now())
+}
+
+// Refresh recomputes every window of the global leaderboard and of each
+// category that is not archived
+func Refresh(db *gorm.DB) error {
+	var categories []string
+	if err := db.Table("categories").Where("archived = ?", false).Pluck("slug", &categories).Error; err != nil {
+		return err
+	}
+	weights := DefaultWeights()
+	now := time.Now()
+	for _, category := range append([]string{""}, categories...) {
+		for window := range Windows {
+			if _, err := refresh(db, window, category, weights, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RefreshService refreshes the cached leaderboards every interval, run it
+// in its own goroutine
+func RefreshService(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := Refresh(db); err != nil {
+			logrus.Println(err)
+		}
+		<-ticker.C
+	}
+}
+
+func refresh(db *gorm.DB, window, category string, weights Weights, now time.Time) (Board, error) {
+	board, err := Compute(db, window, category, weights, now)
+	if err != nil {
+		return board, err
+	}
+	if raw, err := json.Marshal(board); err == nil {
+		kvstore.SetKey(cacheKey(window, category), string(raw), CacheTTL)
+	}
+	return board, nil
+}
+
+func cacheKey(window, category string) string {
+	return "leaderboard:" + window + ":" + category
+}
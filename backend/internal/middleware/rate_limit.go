@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"microblog/backend/internal/helper"
+	"microblog/backend/pkg/kvstore"
+	"microblog/backend/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrRateLimited is the error code of requests over their rate limit
+const ErrRateLimited = "RATE_LIMITED"
+
+// What a rate limit policy counts requests by
+const (
+	ByUser = "user" // the signed in user, the client IP for anonymous requests
+	ByIP   = "ip"
+)
+
+// Limit allows Requests per Window, a Limit without requests is unlimited
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Policy is a named rate limit. The limit of a request is the one of the
+// user's role in Roles, Limit otherwise. RATE_LIMIT_<NAME> overrides Limit,
+// as "<requests>/<window>" like "100/24h".
+type Policy struct {
+	Name  string
+	By    string
+	Limit Limit
+	Roles map[uint]Limit
+}
+
+// RateLimit limits the requests of a route to policy with a sliding window:
+// the count of the current window plus the previous window's count weighted
+// by how much of it still overlaps. Counters live in kvstore, in Redis when
+// it is up. A failing store lets requests through.
+func RateLimit(policy Policy) gin.HandlerFunc {
+	policy = policy.FromEnv()
+	return func(c *gin.Context) {
+		if limit, retry, ok := policy.Take(c); !ok {
+			policy.Reject(c, limit, retry)
+			return
+		}
+		c.Next()
+	}
+}
+
+// FromEnv applies the RATE_LIMIT_<NAME> override of the policy
+func (policy Policy) FromEnv() Policy {
+	if env := util.Getenv("RATE_LIMIT_"+strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(policy.Name)), ""); env != "" {
+		if limit, err := ParseLimit(env); err == nil {
+			policy.Limit = limit
+		} else {
+			logrus.Errorf("rate limit %s: %v", policy.Name, err)
+		}
+	}
+	return policy
+}
+
+// Take counts the request of c against the policy and sets the
+// X-RateLimit headers, for handlers that only limit some of their requests.
+// The request is counted first and the returned count checked, so concurrent
+// requests cannot all pass on the same stale count. Over the limit Take
+// returns false with the limit that applies and how long to wait, see Reject.
+func (policy Policy) Take(c *gin.Context) (Limit, time.Duration, bool) {
+	limit, subject := policy.Limit, "ip:"+c.ClientIP()
+	if policy.By == ByUser {
+		if user, err := helper.GetFirebaseUser(c); err == nil {
+			subject = "user:" + user.ID
+			if roleLimit, ok := policy.Roles[user.RoleID]; ok {
+				limit = roleLimit
+			}
+		}
+	}
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return limit, 0, true
+	}
+
+	now := time.Now()
+	window := now.UnixNano() / int64(limit.Window)
+	elapsed := float64(now.UnixNano()%int64(limit.Window)) / float64(limit.Window)
+	key := "ratelimit:" + policy.Name + ":" + subject + ":"
+	current, err := kvstore.IncrKey(key+strconv.FormatInt(window, 10), 2*limit.Window)
+	if err != nil {
+		logrus.Println(err)
+		return limit, 0, true
+	}
+	previous := counter(key + strconv.FormatInt(window-1, 10))
+
+	reset := time.Unix(0, (window+1)*int64(limit.Window))
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	used := float64(previous)*(1-elapsed) + float64(current)
+	if used > float64(limit.Requests) {
+		retry := retryAfter(limit, previous, current+1, elapsed) // the next request counts one more
+		c.Header("X-RateLimit-Remaining", "0")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		return limit, retry, false
+	}
+	remaining := limit.Requests - int(math.Ceil(used))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+	return limit, 0, true
+}
+
+// Reject aborts a request Take refused with 429 Too Many Requests
+func (policy Policy) Reject(c *gin.Context, limit Limit, retry time.Duration) {
+	seconds := int(math.Ceil(retry.Seconds()))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"success": false,
+		"error":   ErrRateLimited,
+		"message": fmt.Sprintf("too many requests, try again in %d seconds", seconds),
+		"data": gin.H{
+			"policy":      policy.Name,
+			"limit":       limit.Requests,
+			"window":      limit.Window.String(),
+			"retry_after": seconds,
+		},
+	})
+}
+
+// ParseLimit parses "<requests>/<window>", like "100/24h" or "5/1m"
+func ParseLimit(s string) (Limit, error) {
+	requests, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, want <requests>/<window>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil {
+		return Limit{}, fmt.Errorf("invalid limit %q: %w", s, err)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil {
+		return Limit{}, fmt.Errorf("invalid limit %q: %w", s, err)
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+func counter(key string) int64 {
+	value, err := kvstore.GetKey(key)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// retryAfter is how long until the sliding window has room for one more
+// request, elapsed being the fraction of the current window gone by
+func retryAfter(limit Limit, previous, current int64, elapsed float64) time.Duration {
+	n := float64(limit.Requests)
+	var wait float64 // in windows
+	if float64(current) < n {
+		// The previous window's weight has to drop below what is left
+		wait = (1 - (n-float64(current))/float64(previous)) - elapsed
+	} else {
+		// Wait for this window to end, then for it to weigh little enough
+		wait = (1 - elapsed) + (1 - n/float64(current))
+	}
+	return max(time.Duration(wait*float64(limit.Window)), time.Second)
+}
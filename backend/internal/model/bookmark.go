@@ -0,0 +1,116 @@
+package model
+
+import (
+	"time"
+
+	"microblog/backend/pkg/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bookmark target types
+const (
+	BookmarkThread  = "threads"
+	BookmarkComment = "comments"
+)
+
+// BookmarkCollection is a named list of a user's bookmarks, only its owner
+// sees it
+type BookmarkCollection struct {
+	ID        string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	UserID    string    `json:"-" gorm:"column:user_id;size:36;uniqueIndex:idx_bookmark_collections_user_slug"`
+	Name      string    `json:"name" gorm:"column:name;size:100"`
+	Slug      string    `json:"slug" gorm:"column:slug;size:100;uniqueIndex:idx_bookmark_collections_user_slug"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (m *BookmarkCollection) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	if m.Slug == "" {
+		m.Slug = util.Slugify(m.Name)
+	}
+	return nil
+}
+
+// TableName overrides the default table name for BookmarkCollection model
+func (BookmarkCollection) TableName() string {
+	return "bookmark_collections"
+}
+
+// Bookmark is a thread or comment a user saved. A target is saved once per
+// user, in at most one collection.
+type Bookmark struct {
+	ID           string              `json:"id" gorm:"primaryKey;column:id;size:36"`
+	UserID       string              `json:"-" gorm:"column:user_id;size:36;uniqueIndex:idx_bookmarks_user_target"`
+	TargetType   string              `json:"target_type" gorm:"column:target_type;size:20;uniqueIndex:idx_bookmarks_user_target"` // threads | comments
+	TargetID     string              `json:"target_id" gorm:"column:target_id;size:36;uniqueIndex:idx_bookmarks_user_target"`
+	ThreadID     string              `json:"thread_id" gorm:"column:thread_id;size:36;index"`
+	CollectionID *string             `json:"collection_id" gorm:"column:collection_id;size:36;index"` // nil when not in a collection
+	Collection   *BookmarkCollection `json:"collection,omitempty" gorm:"foreignKey:CollectionID"`
+	CreatedAt    time.Time           `json:"created_at" gorm:"column:created_at"`
+}
+
+func (m *Bookmark) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Bookmark model
+func (Bookmark) TableName() string {
+	return "bookmarks"
+}
+
+// FindOrCreateCollection returns userID's collection called name, creating it
+// when the user has none with the same slug
+func FindOrCreateCollection(db *gorm.DB, userID, name string) (BookmarkCollection, error) {
+	collection := BookmarkCollection{UserID: userID, Name: name, Slug: util.Slugify(name)}
+	err := db.Where("user_id = ? AND slug = ?", userID, collection.Slug).FirstOrCreate(&collection).Error
+	return collection, err
+}
+
+// SaveBookmark saves the target of bookmark for its user. When it is already
+// saved it moves to bookmark.CollectionID, if one is given, and stays in its
+// collection otherwise.
+func SaveBookmark(db *gorm.DB, bookmark Bookmark) (Bookmark, error) {
+	bookmark.CreatedAt = time.Now()
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "target_type"}, {Name: "target_id"}},
+		DoNothing: true,
+	}
+	if bookmark.CollectionID != nil {
+		onConflict.DoNothing = false
+		onConflict.DoUpdates = clause.AssignmentColumns([]string{"collection_id"})
+	}
+	if err := db.Clauses(onConflict).Create(&bookmark).Error; err != nil {
+		return bookmark, err
+	}
+	// The id and created_at of an existing bookmark win over the new ones
+	var saved Bookmark
+	err := db.Preload("Collection").
+		Where("user_id = ? AND target_type = ? AND target_id = ?", bookmark.UserID, bookmark.TargetType, bookmark.TargetID).
+		First(&saved).Error
+	return saved, err
+}
+
+// BookmarkedIDs tells which of ids userID bookmarked
+func BookmarkedIDs(db *gorm.DB, userID, targetType string, ids []string) map[string]bool {
+	bookmarked := map[string]bool{}
+	if len(ids) == 0 {
+		return bookmarked
+	}
+	var targets []string
+	db.Model(&Bookmark{}).
+		Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, ids).
+		Pluck("target_id", &targets)
+	for _, id := range targets {
+		bookmarked[id] = true
+	}
+	return bookmarked
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"microblog/backend/pkg/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Category is a curated bucket for threads, Thread.Category holds its slug
+type Category struct {
+	ID          string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	Slug        string    `json:"slug" gorm:"column:slug;size:100;uniqueIndex"`
+	Name        string    `json:"name" gorm:"column:name;size:100"`
+	Description string    `json:"description" gorm:"column:description;type:text"`
+	Color       string    `json:"color" gorm:"column:color;size:20"`
+	Icon        string    `json:"icon" gorm:"column:icon;size:50"`
+	Position    int       `json:"position" gorm:"column:position;index"`
+	Archived    bool      `json:"archived" gorm:"column:archived;index"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (m *Category) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	if m.Slug == "" {
+		m.Slug = util.Slugify(m.Name)
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Category model
+func (Category) TableName() string {
+	return "categories"
+}
+
+// Tag is a free-form label, threads can carry several of them
+type Tag struct {
+	ID        string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	Slug      string    `json:"slug" gorm:"column:slug;size:50;uniqueIndex"`
+	Name      string    `json:"name" gorm:"column:name;size:50"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (m *Tag) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Tag model
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// MaxThreadTags is the number of tags a single thread may carry
+const MaxThreadTags = 5
+
+// ParseTags validates tag names and turns them into unsaved tags, one per
+// slug, without touching the database
+func ParseTags(names []string) ([]Tag, error) {
+	tags := []Tag{}
+	seen := map[string]bool{}
+	for _, n := range names {
+		slug := util.Slugify(n)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		if len(slug) > 50 {
+			return nil, fmt.Errorf("tag '%s' is too long", n)
+		}
+		seen[slug] = true
+		tags = append(tags, Tag{Slug: slug, Name: n})
+	}
+	if len(tags) > MaxThreadTags {
+		return nil, fmt.Errorf("a thread can have at most %d tags", MaxThreadTags)
+	}
+	return tags, nil
+}
+
+// FindOrCreateTags resolves tag names to tags by slug, creating the missing ones
+func FindOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	tags, err := ParseTags(names)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tags {
+		if err := tx.Where("slug = ?", tags[i].Slug).FirstOrCreate(&tags[i]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Classification statuses
+const (
+	ClassificationOK          = "ok"
+	ClassificationUnavailable = "unavailable" // the model could not be reached or gave no usable answer
+)
+
+// Classification is what the LLM moderation classifier made of a new thread
+// or comment, kept for the moderators reviewing the report it may have filed
+type Classification struct {
+	ID         string             `json:"id" gorm:"primaryKey;column:id;size:36"`
+	SourceType string             `json:"source_type" gorm:"column:source_type;size:20;index:idx_classifications_source"` // threads | comments
+	SourceID   string             `json:"source_id" gorm:"column:source_id;size:36;index:idx_classifications_source"`
+	ThreadID   string             `json:"thread_id" gorm:"column:thread_id;size:36"`
+	UserID     string             `json:"user_id" gorm:"column:user_id;size:36;index"` // author of the post
+	Provider   string             `json:"provider" gorm:"column:provider;size:20"`
+	Status     string             `json:"status" gorm:"column:status;size:20"`
+	Scores     map[string]float64 `json:"scores" gorm:"column:scores;serializer:json;type:text"` // category: confidence from 0 to 1
+	Flagged    string             `json:"flagged" gorm:"column:flagged;size:100;index"`          // comma separated categories over their threshold
+	Error      string             `json:"error,omitempty" gorm:"column:error;size:500"`
+	CreatedAt  time.Time          `json:"created_at" gorm:"column:created_at;index"`
+}
+
+func (c *Classification) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Classification model
+func (Classification) TableName() string {
+	return "classifications"
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Follow target types
+const (
+	FollowUser     = "users"
+	FollowCategory = "categories"
+)
+
+// Follow is a user following another user or a category, what they follow
+// makes up their GET /feed. TargetID is a user id or a category slug.
+type Follow struct {
+	FollowerID string    `json:"follower_id" gorm:"primaryKey;column:follower_id;size:36"`
+	TargetType string    `json:"target_type" gorm:"primaryKey;column:target_type;size:20;index:idx_follows_target"` // users | categories
+	TargetID   string    `json:"target_id" gorm:"primaryKey;column:target_id;size:100;index:idx_follows_target"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName overrides the default table name for Follow model
+func (Follow) TableName() string {
+	return "follows"
+}
+
+// SetFollow makes followerID follow or unfollow a target and keeps the
+// follower and following counts of the users involved up to date
+func SetFollow(db *gorm.DB, followerID, targetType, targetID string, following bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		follow := Follow{FollowerID: followerID, TargetType: targetType, TargetID: targetID}
+		if following {
+			follow.CreatedAt = time.Now()
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Where(&follow).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+
+		// Recount instead of +1/-1, following twice must not count twice
+		if err := tx.Model(&User{}).Where("id = ?", followerID).
+			UpdateColumn("following_count", tx.Model(&Follow{}).Select("COUNT(*)").Where("follower_id = ?", followerID)).Error; err != nil {
+			return err
+		}
+		if targetType != FollowUser {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", targetID).
+			UpdateColumn("follower_count", tx.Model(&Follow{}).Select("COUNT(*)").Where("target_type = ? AND target_id = ?", FollowUser, targetID)).Error
+	})
+}
+
+// IsFollowing reports whether followerID follows the target
+func IsFollowing(db *gorm.DB, followerID, targetType, targetID string) bool {
+	var n int64
+	db.Model(&Follow{}).Where("follower_id = ? AND target_type = ? AND target_id = ?", followerID, targetType, targetID).Count(&n)
+	return n > 0
+}
+
+// FollowedBy is the subquery of the target ids of targetType followerID follows
+func FollowedBy(db *gorm.DB, followerID, targetType string) *gorm.DB {
+	return db.Model(&Follow{}).Select("target_id").Where("follower_id = ? AND target_type = ?", followerID, targetType)
+}
@@ -10,7 +10,7 @@ type LogActivity struct {
 	ID uint `json:"id" gorm:"column:id;primaryKey"`
 
 	// ===== Actor =====
-	UserID    uint   `json:"user_id" gorm:"column:user_id;index"`
+	UserID    string `json:"user_id" gorm:"column:user_id;size:36;index"`
 	IP        string `json:"ip" gorm:"column:ip;size:45"` // IPv4/IPv6
 	UserAgent string `json:"user_agent" gorm:"column:user_agent;type:text"`
 
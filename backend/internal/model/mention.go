@@ -0,0 +1,183 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Mention source types
+const (
+	MentionSourceThread  = "threads"
+	MentionSourceComment = "comments"
+)
+
+// mentionPattern matches @username not preceded by a word character, so
+// e-mail addresses are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9_]{1,50})`)
+
+// Mention links an @username in a thread body or comment to the user. Offset
+// and Length are in UTF-16 code units, the way JavaScript indexes strings.
+type Mention struct {
+	ID         string    `json:"-" gorm:"primaryKey;column:id;size:36"`
+	SourceType string    `json:"-" gorm:"column:source_type;size:20;index:idx_mentions_source"`
+	SourceID   string    `json:"-" gorm:"column:source_id;size:36;index:idx_mentions_source"`
+	UserID     string    `json:"user_id" gorm:"column:user_id;size:36;index"`
+	Username   string    `json:"username" gorm:"column:username;size:50"`
+	Offset     int       `json:"offset" gorm:"column:mention_offset"`
+	Length     int       `json:"length" gorm:"column:mention_length"`
+	CreatedAt  time.Time `json:"-" gorm:"column:created_at"`
+}
+
+func (m *Mention) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Mention model
+func (Mention) TableName() string {
+	return "mentions"
+}
+
+// MentionToken is an @username found in a text
+type MentionToken struct {
+	Username string
+	Offset   int // UTF-16 code units
+	Length   int // UTF-16 code units, including the @
+}
+
+// ParseMentions finds every @username in text
+func ParseMentions(text string) []MentionToken {
+	var tokens []MentionToken
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		at := m[2] - 1 // the @ right before the captured username
+		tokens = append(tokens, MentionToken{
+			Username: text[m[2]:m[3]],
+			Offset:   utf16Len(text[:at]),
+			Length:   utf16Len(text[at:m[3]]),
+		})
+	}
+	return tokens
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// SyncMentions re-reads the text of a thread or comment and replaces its
+// mentions, so edits add and drop mentions. Newly mentioned users get a
+// notification.
+func SyncMentions(tx *gorm.DB, sourceType, sourceID string) error {
+	// Called from model hooks, start a fresh statement on the same connection
+	tx = tx.Session(&gorm.Session{NewDB: true})
+
+	textColumn := "body"
+	threadColumn := "id"
+	if sourceType == MentionSourceComment {
+		textColumn = "content"
+		threadColumn = "thread_id"
+	}
+	var source struct {
+		Text       string
+		UserID     string
+		ThreadID   string
+		Moderation string
+	}
+	if err := tx.Table(sourceType).
+		Select(textColumn+" AS text, user_id, "+threadColumn+" AS thread_id, moderation").
+		Where("id = ?", sourceID).
+		Limit(1).
+		Scan(&source).Error; err != nil {
+		return err
+	}
+
+	tokens := ParseMentions(source.Text)
+	users := map[string]User{}
+	if len(tokens) > 0 {
+		var names []string
+		for _, t := range tokens {
+			names = append(names, strings.ToLower(t.Username))
+		}
+		var found []User
+		if err := tx.Select("id", "username").
+			Where("LOWER(username) IN ?", names).
+			Order("created_at").
+			Find(&found).Error; err != nil {
+			return err
+		}
+		for _, u := range found {
+			key := strings.ToLower(u.Username)
+			if _, ok := users[key]; !ok {
+				users[key] = u
+			}
+		}
+	}
+
+	var before []string
+	tx.Model(&Mention{}).
+		Where("source_type = ? AND source_id = ?", sourceType, sourceID).
+		Distinct().
+		Pluck("user_id", &before)
+	previously := map[string]bool{}
+	for _, id := range before {
+		previously[id] = true
+	}
+
+	var mentions []Mention
+	for _, t := range tokens {
+		u, ok := users[strings.ToLower(t.Username)]
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, Mention{
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			UserID:     u.ID,
+			Username:   u.Username,
+			Offset:     t.Offset,
+			Length:     t.Length,
+		})
+	}
+
+	if err := tx.Where("source_type = ? AND source_id = ?", sourceType, sourceID).Delete(&Mention{}).Error; err != nil {
+		return err
+	}
+	if len(mentions) > 0 {
+		if err := tx.Create(&mentions).Error; err != nil {
+			return err
+		}
+	}
+
+	// Nobody else can read held or hidden content yet
+	if source.Moderation != ModerationVisible {
+		return nil
+	}
+	var commentID *string
+	if sourceType == MentionSourceComment {
+		commentID = &sourceID
+	}
+	notified := map[string]bool{}
+	for _, m := range mentions {
+		if previously[m.UserID] || notified[m.UserID] {
+			continue
+		}
+		notified[m.UserID] = true
+		if err := Notify(tx, m.UserID, source.UserID, NotifyMention, source.ThreadID, commentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMentions drops the mentions of a deleted thread or comment
+func RemoveMentions(tx *gorm.DB, sourceType, sourceID string) error {
+	return tx.Session(&gorm.Session{NewDB: true}).
+		Where("source_type = ? AND source_id = ?", sourceType, sourceID).
+		Delete(&Mention{}).Error
+}
@@ -0,0 +1,230 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotifyThreadComment = "thread.comment" // someone commented on a thread you watch
+	NotifyCommentReply  = "comment.reply"  // someone replied to your comment
+	NotifyThreadUpvote  = "thread.upvote"
+	NotifyCommentUpvote = "comment.upvote"
+	NotifyMention       = "mention" // someone mentioned you with @username
+	NotifyWarning       = "moderation.warning"
+)
+
+// NotificationGroupWindow is how long an unread notification keeps absorbing
+// new activity of the same kind ("5 people upvoted your thread")
+const NotificationGroupWindow = 24 * time.Hour
+
+// Notification tells a user about activity on their content. Repeated
+// activity on the same target is grouped into one row while it is unread.
+type Notification struct {
+	ID         string     `json:"id" gorm:"primaryKey;column:id;size:36"`
+	UserID     string     `json:"user_id" gorm:"column:user_id;size:36;index:idx_notifications_user_read"`
+	Type       string     `json:"type" gorm:"column:type;size:50"`
+	GroupKey   string     `json:"-" gorm:"column:group_key;size:150;index"`
+	ThreadID   string     `json:"thread_id" gorm:"column:thread_id;size:36;index"`
+	CommentID  *string    `json:"comment_id" gorm:"column:comment_id;size:36"`
+	ActorID    string     `json:"actor_id" gorm:"column:actor_id;size:36"` // latest actor
+	Actor      User       `json:"actor" gorm:"foreignKey:ActorID"`
+	ActorCount int        `json:"actor_count" gorm:"column:actor_count"` // distinct actors in the group
+	Note       string     `json:"note" gorm:"column:note;type:text"`     // moderator message of a warning
+	ReadAt     *time.Time `json:"read_at" gorm:"column:read_at;index:idx_notifications_user_read"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"column:updated_at;index"`
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Notification model
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// Message renders the notification for display, e.g. "Alice and 4 others
+// upvoted your thread"
+func (n Notification) Message() string {
+	who := n.Actor.Name
+	if who == "" {
+		who = "Someone"
+	}
+	switch {
+	case n.ActorCount == 2:
+		who += " and 1 other"
+	case n.ActorCount > 2:
+		who += fmt.Sprintf(" and %d others", n.ActorCount-1)
+	}
+	switch n.Type {
+	case NotifyThreadComment:
+		return who + " commented on a thread you follow"
+	case NotifyCommentReply:
+		return who + " replied to your comment"
+	case NotifyThreadUpvote:
+		return who + " upvoted your thread"
+	case NotifyCommentUpvote:
+		return who + " upvoted your comment"
+	case NotifyMention:
+		return who + " mentioned you"
+	case NotifyWarning:
+		if n.Note != "" {
+			return "A moderator warned you: " + n.Note
+		}
+		return "A moderator warned you about your content"
+	}
+	return who + " was active on your content"
+}
+
+// NotificationActor records who contributed to a grouped notification, so
+// the same person is counted once
+type NotificationActor struct {
+	NotificationID string `gorm:"primaryKey;column:notification_id;size:36"`
+	UserID         string `gorm:"primaryKey;column:user_id;size:36"`
+}
+
+// TableName overrides the default table name for NotificationActor model
+func (NotificationActor) TableName() string {
+	return "notification_actors"
+}
+
+// ThreadWatch is a user's explicit watch setting for a thread. Authors watch
+// their own threads unless they turned it off.
+type ThreadWatch struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;column:user_id;size:36"`
+	ThreadID  string    `json:"thread_id" gorm:"primaryKey;column:thread_id;size:36;index"`
+	Watching  bool      `json:"watching" gorm:"column:watching"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name for ThreadWatch model
+func (ThreadWatch) TableName() string {
+	return "thread_watches"
+}
+
+// IsWatching reports whether userID gets comment notifications for thread
+func IsWatching(db *gorm.DB, thread Thread, userID string) bool {
+	var watch ThreadWatch
+	if db.Where("user_id = ? AND thread_id = ?", userID, thread.ID).Limit(1).Find(&watch).RowsAffected > 0 {
+		return watch.Watching
+	}
+	return thread.UserID == userID
+}
+
+// ThreadWatchers returns everyone watching the thread: explicit watchers
+// plus the author, unless the author opted out
+func ThreadWatchers(db *gorm.DB, thread Thread) ([]string, error) {
+	var watches []ThreadWatch
+	if err := db.Where("thread_id = ?", thread.ID).Find(&watches).Error; err != nil {
+		return nil, err
+	}
+	authorSet := false
+	var users []string
+	for _, w := range watches {
+		if w.UserID == thread.UserID {
+			authorSet = true
+		}
+		if w.Watching {
+			users = append(users, w.UserID)
+		}
+	}
+	if !authorSet {
+		users = append(users, thread.UserID)
+	}
+	return users, nil
+}
+
+// Notify records activity of actorID for userID, folding it into a recent
+// unread notification of the same kind and target when there is one. Users
+// are never notified about their own activity.
+func Notify(db *gorm.DB, userID, actorID, notifType, threadID string, commentID *string) error {
+	if userID == "" || userID == actorID {
+		return nil
+	}
+	target := threadID
+	if commentID != nil && (notifType == NotifyCommentReply || notifType == NotifyCommentUpvote) {
+		target = *commentID
+	}
+	groupKey := notifType + ":" + target
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var n Notification
+		found := tx.Where("user_id = ? AND group_key = ? AND read_at IS NULL AND updated_at >= ?",
+			userID, groupKey, now.Add(-NotificationGroupWindow)).
+			Order("updated_at desc").
+			Limit(1).
+			Find(&n)
+		if found.Error != nil {
+			return found.Error
+		}
+		if found.RowsAffected == 0 {
+			n = Notification{
+				UserID:     userID,
+				Type:       notifType,
+				GroupKey:   groupKey,
+				ThreadID:   threadID,
+				CommentID:  commentID,
+				ActorID:    actorID,
+				ActorCount: 1,
+			}
+			if err := tx.Create(&n).Error; err != nil {
+				return err
+			}
+			return tx.Create(&NotificationActor{NotificationID: n.ID, UserID: actorID}).Error
+		}
+
+		var seen int64
+		tx.Model(&NotificationActor{}).Where("notification_id = ? AND user_id = ?", n.ID, actorID).Count(&seen)
+		if seen == 0 {
+			if err := tx.Create(&NotificationActor{NotificationID: n.ID, UserID: actorID}).Error; err != nil {
+				return err
+			}
+			n.ActorCount++
+		}
+		return tx.Model(&n).Updates(map[string]any{
+			"actor_id":    actorID,
+			"actor_count": n.ActorCount,
+			"comment_id":  commentID,
+			"updated_at":  now,
+		}).Error
+	})
+}
+
+// NotifyComment notifies the thread watchers about a new comment, and the
+// parent comment's author about a reply
+func NotifyComment(db *gorm.DB, thread Thread, comment Comment) error {
+	notified := map[string]bool{}
+	if comment.ParentID != nil && *comment.ParentID != "" {
+		var parent Comment
+		if err := db.Select("id", "user_id").Where("id = ?", *comment.ParentID).First(&parent).Error; err == nil {
+			if err := Notify(db, parent.UserID, comment.UserID, NotifyCommentReply, thread.ID, &comment.ID); err != nil {
+				return err
+			}
+			notified[parent.UserID] = true
+		}
+	}
+
+	watchers, err := ThreadWatchers(db, thread)
+	if err != nil {
+		return err
+	}
+	for _, userID := range watchers {
+		if notified[userID] {
+			continue
+		}
+		if err := Notify(db, userID, comment.UserID, NotifyThreadComment, thread.ID, &comment.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Moderation states of threads and comments
+const (
+	ModerationVisible = ""
+	ModerationHidden  = "hidden"  // only the author and moderators see it
+	ModerationRemoved = "removed" // deleted by a moderator, the author cannot restore it
+	ModerationHeld    = "held"    // held by the spam checks until a moderator reviews it, shown like hidden
+)
+
+// Report target types
+const (
+	ReportTargetThread  = "threads"
+	ReportTargetComment = "comments"
+)
+
+// Report reasons
+const (
+	ReportSpam           = "spam"
+	ReportHarassment     = "harassment"
+	ReportHate           = "hate"
+	ReportViolence       = "violence"
+	ReportSexual         = "sexual"
+	ReportMisinformation = "misinformation"
+	ReportOffTopic       = "off_topic"
+	ReportOther          = "other"
+)
+
+// ReportReasons are the reason codes users can report content for
+var ReportReasons = []string{
+	ReportSpam,
+	ReportHarassment,
+	ReportHate,
+	ReportViolence,
+	ReportSexual,
+	ReportMisinformation,
+	ReportOffTopic,
+	ReportOther,
+}
+
+// Reporter ids of the reports filed automatically
+const (
+	SystemReporter     = "system"     // the spam checks
+	ClassifierReporter = "classifier" // the LLM moderation classifier
+)
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+// Moderator actions on a report
+const (
+	ModerateDismiss = "dismiss"
+	ModerateHide    = "hide"
+	ModerateDelete  = "delete"
+	ModerateWarn    = "warn"
+	ModerateSuspend = "suspend"
+)
+
+// ModerationActions are the actions of the moderation queue
+var ModerationActions = []string{ModerateDismiss, ModerateHide, ModerateDelete, ModerateWarn, ModerateSuspend}
+
+// DefaultSuspension is how long the suspend action suspends a user when the
+// moderator gives no duration
+const DefaultSuspension = 7 * 24 * time.Hour
+
+// Report collects the reports about one thread or comment while it waits in
+// the moderation queue. Reports of the same target fold into the open report.
+type Report struct {
+	ID             string        `json:"id" gorm:"primaryKey;column:id;size:36"`
+	TargetType     string        `json:"target_type" gorm:"column:target_type;size:20;index:idx_reports_target"` // threads | comments
+	TargetID       string        `json:"target_id" gorm:"column:target_id;size:36;index:idx_reports_target"`
+	ThreadID       string        `json:"thread_id" gorm:"column:thread_id;size:36;index"`
+	TargetUserID   string        `json:"target_user_id" gorm:"column:target_user_id;size:36;index"` // author of the reported content
+	TargetUser     User          `json:"target_user" gorm:"foreignKey:TargetUserID"`
+	Status         string        `json:"status" gorm:"column:status;size:20;index"`
+	ReportCount    int           `json:"report_count" gorm:"column:report_count"` // distinct reporters
+	Entries        []ReportEntry `json:"entries" gorm:"foreignKey:ReportID"`
+	Action         string        `json:"action" gorm:"column:action;size:20"` // moderator action that closed the report
+	Note           string        `json:"note" gorm:"column:note;type:text"`   // moderator note
+	ResolvedByID   *string       `json:"resolved_by_id" gorm:"column:resolved_by_id;size:36"`
+	ResolvedAt     *time.Time    `json:"resolved_at" gorm:"column:resolved_at"`
+	LastReportedAt time.Time     `json:"last_reported_at" gorm:"column:last_reported_at;index"`
+	CreatedAt      time.Time     `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (r *Report) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Report model
+func (Report) TableName() string {
+	return "reports"
+}
+
+// ReportEntry is one user's report, a user reports a target once
+type ReportEntry struct {
+	ReportID   string    `json:"-" gorm:"primaryKey;column:report_id;size:36"`
+	ReporterID string    `json:"reporter_id" gorm:"primaryKey;column:reporter_id;size:36"`
+	Reason     string    `json:"reason" gorm:"column:reason;size:30"`
+	Note       string    `json:"note" gorm:"column:note;size:500"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName overrides the default table name for ReportEntry model
+func (ReportEntry) TableName() string {
+	return "report_entries"
+}
+
+// FileReport adds reporterID's report to the open report of the target,
+// opening one when there is none. duplicate is true when the user already
+// reported the target.
+func FileReport(db *gorm.DB, target Report, reporterID, reason, note string) (report Report, duplicate bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		found := tx.Where("target_type = ? AND target_id = ? AND status = ?", target.TargetType, target.TargetID, ReportOpen).
+			Limit(1).
+			Find(&report)
+		if found.Error != nil {
+			return found.Error
+		}
+		now := time.Now()
+		if found.RowsAffected == 0 {
+			report = target
+			report.Status = ReportOpen
+			report.LastReportedAt = now
+			if err := tx.Create(&report).Error; err != nil {
+				return err
+			}
+		}
+
+		var seen int64
+		tx.Model(&ReportEntry{}).Where("report_id = ? AND reporter_id = ?", report.ID, reporterID).Count(&seen)
+		if seen > 0 {
+			duplicate = true
+			return nil
+		}
+		if err := tx.Create(&ReportEntry{
+			ReportID:   report.ID,
+			ReporterID: reporterID,
+			Reason:     reason,
+			Note:       note,
+		}).Error; err != nil {
+			return err
+		}
+		report.ReportCount++
+		report.LastReportedAt = now
+		return tx.Model(&report).Updates(map[string]any{
+			"report_count":     report.ReportCount,
+			"last_reported_at": now,
+		}).Error
+	})
+	return report, duplicate, err
+}
+
+// Visible limits a threads or comments query to the rows viewer may see:
+// content hidden by moderators or held by the spam checks is only shown to
+// its author and moderators.
+// viewer is nil for anonymous requests.
+func Visible(table string, viewer *User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case viewer != nil && viewer.IsModerator():
+			return db
+		case viewer != nil:
+			return db.Where("("+table+".moderation = ? OR "+table+".user_id = ?)", ModerationVisible, viewer.ID)
+		}
+		return db.Where(table+".moderation = ?", ModerationVisible)
+	}
+}
@@ -0,0 +1,232 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reputation event reasons
+const (
+	RepThreadUpvoted    = "thread.upvoted"
+	RepThreadDownvoted  = "thread.downvoted"
+	RepCommentUpvoted   = "comment.upvoted"
+	RepCommentDownvoted = "comment.downvoted"
+	RepCommentPosted    = "comment.posted"
+	RepAnswerAccepted   = "answer.accepted" // the thread author accepted the comment as the answer
+	RepWarned           = "moderation.warned"
+	RepSuspended        = "moderation.suspended"
+	RepContentRemoved   = "moderation.removed"
+)
+
+// ReputationPoints are the karma points of each reason
+var ReputationPoints = map[string]int{
+	RepThreadUpvoted:    10,
+	RepThreadDownvoted:  -2,
+	RepCommentUpvoted:   5,
+	RepCommentDownvoted: -1,
+	RepCommentPosted:    1,
+	RepAnswerAccepted:   15,
+	RepWarned:           -10,
+	RepSuspended:        -50,
+	RepContentRemoved:   -20,
+}
+
+// RepPenalties are the reasons of moderation penalties, only the user and
+// moderators see them in the ledger
+var RepPenalties = []string{RepWarned, RepSuspended, RepContentRemoved}
+
+// ReputationEvent is one entry of the karma ledger. Entries are never edited
+// away: undoing one, like a vote taken back, adds a reversal entry with the
+// opposite points and marks the original as reversed.
+type ReputationEvent struct {
+	ID         string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	UserID     string    `json:"user_id" gorm:"column:user_id;size:36;index:idx_reputation_events_user"` // who gains or loses the points
+	ActorID    string    `json:"-" gorm:"column:actor_id;size:36;index:idx_reputation_events_source"`    // voter, commenter or moderator
+	Reason     string    `json:"reason" gorm:"column:reason;size:50"`
+	Points     int       `json:"points" gorm:"column:points"`
+	SourceType string    `json:"source_type" gorm:"column:source_type;size:20;index:idx_reputation_events_source"` // threads | comments | reports
+	SourceID   string    `json:"source_id" gorm:"column:source_id;size:36;index:idx_reputation_events_source"`
+	ThreadID   string    `json:"thread_id" gorm:"column:thread_id;size:36"`
+	ReversesID *string   `json:"reverses_id" gorm:"column:reverses_id;size:36"` // set on reversal entries
+	Reversed   bool      `json:"reversed" gorm:"column:reversed;not null;default:false"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;index:idx_reputation_events_user"`
+}
+
+func (e *ReputationEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for ReputationEvent model
+func (ReputationEvent) TableName() string {
+	return "reputation_events"
+}
+
+// AwardReputation writes event to the ledger with the points of its reason
+// and adds them to the user's karma
+func AwardReputation(tx *gorm.DB, event ReputationEvent) error {
+	event.Points = ReputationPoints[event.Reason]
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ?", event.UserID).UpdateColumn("karma", gorm.Expr("karma + ?", event.Points)).Error
+}
+
+// ReverseReputation undoes a ledger entry with a reversal entry
+func ReverseReputation(tx *gorm.DB, event ReputationEvent) error {
+	if err := tx.Model(&ReputationEvent{}).Where("id = ?", event.ID).UpdateColumn("reversed", true).Error; err != nil {
+		return err
+	}
+	reversal := ReputationEvent{
+		UserID:     event.UserID,
+		ActorID:    event.ActorID,
+		Reason:     event.Reason,
+		Points:     -event.Points,
+		SourceType: event.SourceType,
+		SourceID:   event.SourceID,
+		ThreadID:   event.ThreadID,
+		ReversesID: &event.ID,
+		Reversed:   true,
+	}
+	if err := tx.Create(&reversal).Error; err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ?", event.UserID).UpdateColumn("karma", gorm.Expr("karma + ?", reversal.Points)).Error
+}
+
+// SyncVoteReputation brings the ledger in line with voterID's current vote
+// on a thread or comment: a changed vote reverses the points of the old one
+// and awards the new one, a neutral vote only reverses. Votes on your own
+// content earn nothing.
+func SyncVoteReputation(tx *gorm.DB, sourceType, sourceID, voterID, voteType string) error {
+	var owner struct {
+		UserID   string
+		ThreadID string
+	}
+	if sourceType == RevisionSourceComment {
+		tx.Model(&Comment{}).Unscoped().Select("user_id", "thread_id").Where("id = ?", sourceID).Scan(&owner)
+	} else {
+		tx.Model(&Thread{}).Unscoped().Select("user_id", "id AS thread_id").Where("id = ?", sourceID).Scan(&owner)
+	}
+	if owner.UserID == "" || owner.UserID == voterID {
+		return nil
+	}
+
+	reason := ""
+	switch {
+	case sourceType == RevisionSourceComment && voteType == "up":
+		reason = RepCommentUpvoted
+	case sourceType == RevisionSourceComment && voteType == "down":
+		reason = RepCommentDownvoted
+	case voteType == "up":
+		reason = RepThreadUpvoted
+	case voteType == "down":
+		reason = RepThreadDownvoted
+	}
+
+	var live []ReputationEvent
+	if err := tx.Where("actor_id = ? AND source_type = ? AND source_id = ? AND reversed = ? AND reason IN ?",
+		voterID, sourceType, sourceID, false,
+		[]string{RepThreadUpvoted, RepThreadDownvoted, RepCommentUpvoted, RepCommentDownvoted}).
+		Find(&live).Error; err != nil {
+		return err
+	}
+	for _, event := range live {
+		if event.Reason == reason {
+			// Already counted, saving the same vote twice changes nothing
+			reason = ""
+			continue
+		}
+		if err := ReverseReputation(tx, event); err != nil {
+			return err
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	return AwardReputation(tx, ReputationEvent{
+		UserID:     owner.UserID,
+		ActorID:    voterID,
+		Reason:     reason,
+		SourceType: sourceType,
+		SourceID:   sourceID,
+		ThreadID:   owner.ThreadID,
+	})
+}
+
+// AcceptAnswer makes comment the accepted answer of thread, nil unaccepts.
+// The author of the previously accepted comment loses the points, the new
+// one earns them unless they accept their own comment.
+func AcceptAnswer(tx *gorm.DB, thread Thread, comment *Comment) error {
+	var live []ReputationEvent
+	if err := tx.Where("thread_id = ? AND reason = ? AND reversed = ?", thread.ID, RepAnswerAccepted, false).
+		Find(&live).Error; err != nil {
+		return err
+	}
+	counted := false
+	for _, event := range live {
+		if comment != nil && event.SourceID == comment.ID {
+			// Accepting the same comment again changes nothing
+			counted = true
+			continue
+		}
+		if err := ReverseReputation(tx, event); err != nil {
+			return err
+		}
+	}
+
+	var accepted *string
+	if comment != nil {
+		accepted = &comment.ID
+	}
+	// UpdateColumn leaves updated_at alone, accepting is not an edit
+	if err := tx.Model(&Thread{}).Where("id = ?", thread.ID).UpdateColumn("accepted_comment_id", accepted).Error; err != nil {
+		return err
+	}
+	if comment == nil || counted || comment.UserID == thread.UserID {
+		return nil
+	}
+	return AwardReputation(tx, ReputationEvent{
+		UserID:     comment.UserID,
+		ActorID:    thread.UserID,
+		Reason:     RepAnswerAccepted,
+		SourceType: RevisionSourceComment,
+		SourceID:   comment.ID,
+		ThreadID:   thread.ID,
+	})
+}
+
+// AwardCommentPosted credits the author of a published comment. Comments held
+// by the spam checks earn it when a moderator releases them.
+func AwardCommentPosted(tx *gorm.DB, c Comment) error {
+	return AwardReputation(tx, ReputationEvent{
+		UserID:     c.UserID,
+		ActorID:    c.UserID,
+		Reason:     RepCommentPosted,
+		SourceType: RevisionSourceComment,
+		SourceID:   c.ID,
+		ThreadID:   c.ThreadID,
+	})
+}
+
+// RevokeComments reverses what comments earned their authors by being posted
+// and accepted as the answer, and unaccepts them. For comments that are
+// purged or removed by a moderator, the votes they got are left alone.
+func RevokeComments(tx *gorm.DB, ids []string) error {
+	var live []ReputationEvent
+	if err := tx.Where("source_type = ? AND source_id IN ? AND reason IN ? AND reversed = ?",
+		RevisionSourceComment, ids, []string{RepCommentPosted, RepAnswerAccepted}, false).
+		Find(&live).Error; err != nil {
+		return err
+	}
+	for _, event := range live {
+		if err := ReverseReputation(tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Model(&Thread{}).Where("accepted_comment_id IN ?", ids).UpdateColumn("accepted_comment_id", nil).Error
+}
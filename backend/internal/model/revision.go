@@ -0,0 +1,127 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Revision source types
+const (
+	RevisionSourceThread  = "threads"
+	RevisionSourceComment = "comments"
+)
+
+// Revision is one version of an edited thread or comment. Version 1 is the
+// original post, the highest version is the current text. Posts that were
+// never edited have no revisions.
+type Revision struct {
+	ID         string    `json:"-" gorm:"primaryKey;column:id;size:36"`
+	SourceType string    `json:"-" gorm:"column:source_type;size:20;uniqueIndex:idx_revisions_source_version"`
+	SourceID   string    `json:"-" gorm:"column:source_id;size:36;uniqueIndex:idx_revisions_source_version"`
+	Version    int       `json:"version" gorm:"column:version;uniqueIndex:idx_revisions_source_version"`
+	Title      string    `json:"title,omitempty" gorm:"column:title;size:255"`
+	Body       string    `json:"body,omitempty" gorm:"column:body;type:text"`
+	Category   string    `json:"category,omitempty" gorm:"column:category;size:100"`
+	Content    string    `json:"content,omitempty" gorm:"column:content;type:text"`
+	EditorID   string    `json:"editor_id" gorm:"column:editor_id;size:36"`
+	Editor     User      `json:"editor" gorm:"foreignKey:EditorID"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (r *Revision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName overrides the default table name for Revision model
+func (Revision) TableName() string {
+	return "revisions"
+}
+
+// Fields returns the diffable fields of the revision in display order
+func (r Revision) Fields() [][2]string {
+	if r.SourceType == RevisionSourceComment {
+		return [][2]string{{"content", r.Content}}
+	}
+	return [][2]string{{"title", r.Title}, {"category", r.Category}, {"body", r.Body}}
+}
+
+// ThreadRevision snapshots the editable fields of a thread
+func ThreadRevision(t Thread, editorID string, at time.Time) Revision {
+	return Revision{
+		SourceType: RevisionSourceThread,
+		SourceID:   t.ID,
+		Title:      t.Title,
+		Body:       t.Body,
+		Category:   t.Category,
+		EditorID:   editorID,
+		CreatedAt:  at,
+	}
+}
+
+// CommentRevision snapshots the editable fields of a comment
+func CommentRevision(c Comment, editorID string, at time.Time) Revision {
+	return Revision{
+		SourceType: RevisionSourceComment,
+		SourceID:   c.ID,
+		Content:    c.Content,
+		EditorID:   editorID,
+		CreatedAt:  at,
+	}
+}
+
+// RecordRevision stores the new version of an edited post and returns its
+// version number. The first edit also stores the original as version 1, so
+// posts written before revisions existed get a complete history.
+//
+// Concurrent edits of a post are serialized on its row: the no-op update
+// holds the row's write lock until tx commits, on every database, so the
+// next editor reads the version this one stored instead of hitting the
+// unique index with the same number.
+func RecordRevision(tx *gorm.DB, original, edited Revision) (int, error) {
+	if err := tx.Table(edited.SourceType).Where("id = ?", edited.SourceID).
+		UpdateColumn("id", gorm.Expr("id")).Error; err != nil {
+		return 0, err
+	}
+	var latest int
+	if err := tx.Model(&Revision{}).
+		Where("source_type = ? AND source_id = ?", edited.SourceType, edited.SourceID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return 0, err
+	}
+	if latest == 0 {
+		original.Version = 1
+		if err := tx.Create(&original).Error; err != nil {
+			return 0, err
+		}
+		latest = 1
+	}
+	edited.Version = latest + 1
+	if err := tx.Create(&edited).Error; err != nil {
+		return 0, err
+	}
+	return edited.Version, nil
+}
+
+// ListRevisions returns every version of a post, oldest first. A post that
+// was never edited has a single version: current, by its author.
+func ListRevisions(db *gorm.DB, current Revision) ([]Revision, error) {
+	var revisions []Revision
+	if err := db.Preload("Editor").
+		Where("source_type = ? AND source_id = ?", current.SourceType, current.SourceID).
+		Order("version").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		current.Version = 1
+		db.Where("id = ?", current.EditorID).Limit(1).Find(&current.Editor)
+		revisions = append(revisions, current)
+	}
+	return revisions, nil
+}
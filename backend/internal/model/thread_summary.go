@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ThreadSummary is the cached LLM summary of a thread, written again once
+// enough new comments arrive or the thread is edited
+type ThreadSummary struct {
+	ThreadID         string    `json:"thread_id" gorm:"primaryKey;column:thread_id;size:36"`
+	Summary          string    `json:"summary" gorm:"column:summary;type:text"`
+	Provider         string    `json:"provider" gorm:"column:provider;size:20"`
+	CommentCount     int       `json:"comment_count" gorm:"column:comment_count"`         // visible comments of the thread when it was written
+	CommentsIncluded int       `json:"comments_included" gorm:"column:comments_included"` // how many of them fit the prompt
+	EditCount        int       `json:"-" gorm:"column:edit_count"`                        // Thread.EditCount when it was written
+	PromptTokens     int       `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	GeneratedByID    string    `json:"-" gorm:"column:generated_by_id;size:64"` // user or ip:<address> who had it written
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at"`     // when it was written
+}
+
+// TableName overrides the default table name for ThreadSummary model
+func (ThreadSummary) TableName() string {
+	return "thread_summaries"
+}
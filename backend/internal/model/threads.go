@@ -3,27 +3,58 @@ package model
 import (
 	"time"
 
+	"microblog/backend/internal/embedding"
+	"microblog/backend/internal/ranking"
+	"microblog/backend/internal/search"
+	"microblog/backend/pkg/markdown"
+	"microblog/backend/pkg/types"
+
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 type Thread struct {
-	ID             string       `json:"id" gorm:"primaryKey;column:id;size:36" ui:"sortable"`
-	Title          string       `json:"title" gorm:"column:title;size:255" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	Body           string       `json:"body" gorm:"column:body;type:text" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	Category       string       `json:"category" gorm:"column:category;size:100" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	CreatedAt      time.Time    `json:"created_at" gorm:"column:created_at" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	UpdatedAt      time.Time    `json:"updated_at" gorm:"column:updated_at" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	UserID         string       `json:"user_id" gorm:"column:user_id;size:36" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	User           User         `json:"user" gorm:"foreignKey:UserID" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	TotalUpVotes   int          `json:"total_up_votes" gorm:"column:total_up_votes" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	TotalDownVotes int          `json:"total_down_votes" gorm:"column:total_down_votes" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	UpVotedByMe    bool         `json:"up_voted_by_me" gorm:"-" ui:"visible;sortable"`
-	DownVotedByMe  bool         `json:"down_voted_by_me" gorm:"-" ui:"visible;sortable"`
-	TotalComments  int          `json:"total_comments" gorm:"column:total_comments" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
-	Votes          []ThreadVote `json:"votes" gorm:"foreignKey:ThreadID" ui:"visible;sortable"`
-	Comments       []Comment    `json:"comments" gorm:"foreignKey:ThreadID" ui:"visible;sortable"`
+	ID             string     `json:"id" gorm:"primaryKey;column:id;size:36" ui:"sortable"`
+	Title          string     `json:"title" gorm:"column:title;size:255" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	Body           string     `json:"body" gorm:"column:body;type:text" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	BodyHTML       types.HTML `json:"body_html" gorm:"column:body_html;type:text" ui:"visible"` // rendered Body, kept in sync by BeforeSave
+	Category       string     `json:"category" gorm:"column:category;size:100" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"column:updated_at" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	UserID         string     `json:"user_id" gorm:"column:user_id;size:36" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	User           User       `json:"user" gorm:"foreignKey:UserID" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	TotalUpVotes   int        `json:"total_up_votes" gorm:"column:total_up_votes" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	TotalDownVotes int        `json:"total_down_votes" gorm:"column:total_down_votes" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	UpVotedByMe    bool       `json:"up_voted_by_me" gorm:"-" ui:"visible;sortable"`
+	DownVotedByMe  bool       `json:"down_voted_by_me" gorm:"-" ui:"visible;sortable"`
+	WatchedByMe    bool       `json:"watched_by_me" gorm:"-"`
+	BookmarkedByMe bool       `json:"bookmarked_by_me" gorm:"-" ui:"visible"`
+	TotalComments  int        `json:"total_comments" gorm:"column:total_comments" ui:"creatable;visible;visibility;editable;filterable;;sortable"`
+	// Edit history, see Revision
+	EditCount  int            `json:"edit_count" gorm:"column:edit_count" ui:"visible;filterable;sortable"`
+	EditedAt   *time.Time     `json:"edited_at" gorm:"column:edited_at" ui:"visible;filterable;sortable"`
+	Edited     bool           `json:"edited" gorm:"-" ui:"visible"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"column:deleted_at;index" ui:"visible;filterable;sortable"`                    // soft delete, see PurgeTrash
+	Moderation string         `json:"moderation" gorm:"column:moderation;size:20;not null;default:'';index" ui:"visible;filterable"` // see ModerationHidden
+	// Moderator flags, see PUT_THREADS_ID_LOCK_HANDLER and friends
+	Locked           bool       `json:"locked" gorm:"column:locked;not null;default:false" ui:"visible;filterable;sortable"`                               // no new comments or votes
+	Pinned           bool       `json:"pinned" gorm:"column:pinned;not null;default:false;index" ui:"visible;filterable;sortable"`                         // leads every GET /threads
+	PinnedInCategory bool       `json:"pinned_in_category" gorm:"column:pinned_in_category;not null;default:false;index" ui:"visible;filterable;sortable"` // leads GET /threads?category=
+	FeaturedUntil    *time.Time `json:"featured_until" gorm:"column:featured_until;index" ui:"visible;filterable;sortable"`
+	Featured         bool       `json:"featured" gorm:"-" ui:"visible"`
+	// The comment the thread author accepted as the answer, see AcceptAnswer
+	AcceptedCommentID *string `json:"accepted_comment_id" gorm:"column:accepted_comment_id;size:36" ui:"visible;filterable"`
+	// Ranking scores, maintained by the ranking package (sort=hot|top|rising|controversial)
+	Score              int          `json:"score" gorm:"column:score;index" ui:"visible;filterable;sortable"`
+	HotScore           float64      `json:"hot_score" gorm:"column:hot_score;index" ui:"sortable"`
+	RisingScore        float64      `json:"rising_score" gorm:"column:rising_score;index" ui:"sortable"`
+	ControversialScore float64      `json:"controversial_score" gorm:"column:controversial_score;index" ui:"sortable"`
+	Votes              []ThreadVote `json:"votes" gorm:"foreignKey:ThreadID" ui:"visible;sortable"`
+	Comments           []Comment    `json:"comments" gorm:"foreignKey:ThreadID" ui:"visible;sortable"`
+	Tags               []Tag        `json:"tags" gorm:"many2many:thread_tags" ui:"visible"`
+	Mentions           []Mention    `json:"mentions" gorm:"polymorphic:Source" ui:"visible"` // @username entities in Body
+	SearchRank         float64      `json:"-" gorm:"column:search_rank;->;-:migration"`      // only set by ?q= searches
 }
 
 func (t *Thread) BeforeCreate(tx *gorm.DB) error {
@@ -33,24 +64,101 @@ func (t *Thread) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Render the Markdown body once on write instead of on every read
+func (t *Thread) BeforeSave(tx *gorm.DB) error {
+	t.BodyHTML = markdown.Render(t.Body)
+	return nil
+}
+
+func (t *Thread) AfterFind(tx *gorm.DB) error {
+	t.Edited = t.EditCount > 0
+	t.Featured = t.IsFeatured(time.Now())
+	return nil
+}
+
+// DefaultFeaturedFor is how long a thread stays featured when the moderator
+// gives no end
+const DefaultFeaturedFor = 7 * 24 * time.Hour
+
+// IsFeatured tells whether the thread is featured at now
+func (t *Thread) IsFeatured(now time.Time) bool {
+	return t.FeaturedUntil != nil && t.FeaturedUntil.After(now)
+}
+
+// Keep the full-text search document and the embedding in sync with the thread
+func (t *Thread) AfterCreate(tx *gorm.DB) error {
+	if err := search.IndexThread(tx, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	embedding.Wake()
+	if err := ranking.RefreshThread(tx, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	if err := SyncMentions(tx, MentionSourceThread, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	return nil
+}
+func (t *Thread) AfterUpdate(tx *gorm.DB) error {
+	if err := search.IndexThread(tx, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	embedding.Wake()
+	if err := SyncMentions(tx, MentionSourceThread, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	return nil
+}
+func (t *Thread) AfterDelete(tx *gorm.DB) error {
+	if err := search.RemoveThread(tx, t.ID); err != nil {
+		logrus.Println(err)
+	}
+	if tx.Statement.Unscoped {
+		if err := RemoveMentions(tx, MentionSourceThread, t.ID); err != nil {
+			logrus.Println(err)
+		}
+		return nil
+	}
+	// Soft delete: tombstone the comments with the thread's timestamp, so
+	// RestoreThread brings back exactly these
+	if err := tx.Session(&gorm.Session{NewDB: true}).
+		Model(&Comment{}).
+		Where("thread_id = ?", t.ID).
+		UpdateColumn("deleted_at", t.DeletedAt).Error; err != nil {
+		logrus.Println(err)
+	}
+	return nil
+}
+
 // TableName overrides the default table name for Thread model
 func (Thread) TableName() string {
 	return "threads"
 }
 
 type Comment struct {
-	ID             string        `json:"id" gorm:"primaryKey;column:id;size:36" ui:"visible;sortable"`
-	ThreadID       string        `json:"thread_id" gorm:"column:thread_id;size:36" ui:"visible;sortable"`
-	UserID         string        `json:"user_id" gorm:"column:user_id;size:36" ui:"visible;sortable"`
-	User           User          `json:"user" gorm:"foreignKey:UserID" ui:"visible;sortable"`
-	Content        string        `json:"content" gorm:"column:content;type:text" ui:"creatable;visible;sortable"`
-	CreatedAt      time.Time     `json:"createdAt" gorm:"column:created_at" ui:"visible;filterable;sortable"`
-	UpdatedAt      time.Time     `json:"updatedAt" gorm:"column:updated_at" ui:"visible;filterable;sortable"`
-	TotalUpVotes   int           `json:"total_up_votes" gorm:"column:total_up_votes" ui:"visible;filterable;sortable"`
-	TotalDownVotes int           `json:"total_down_votes" gorm:"column:total_down_votes" ui:"visible;filterable;sortable"`
-	UpVotedByMe    bool          `json:"up_voted_by_me" gorm:"-" ui:"visible"`
-	DownVotedByMe  bool          `json:"down_voted_by_me" gorm:"-" ui:"visible"`
-	Votes          []CommentVote `json:"votes" gorm:"foreignKey:CommentID" ui:"visible;visibility;sortable"`
+	ID             string         `json:"id" gorm:"primaryKey;column:id;size:36" ui:"visible;sortable"`
+	ThreadID       string         `json:"thread_id" gorm:"column:thread_id;size:36" ui:"visible;sortable"`
+	ParentID       *string        `json:"parent_id" gorm:"column:parent_id;size:36;index" ui:"visible;filterable;sortable"`
+	Depth          int            `json:"depth" gorm:"column:depth" ui:"visible;filterable;sortable"`
+	TotalReplies   int            `json:"total_replies" gorm:"column:total_replies" ui:"visible;filterable;sortable"`
+	UserID         string         `json:"user_id" gorm:"column:user_id;size:36" ui:"visible;sortable"`
+	User           User           `json:"user" gorm:"foreignKey:UserID" ui:"visible;sortable"`
+	Content        string         `json:"content" gorm:"column:content;type:text" ui:"creatable;visible;sortable"`
+	ContentHTML    types.HTML     `json:"content_html" gorm:"column:content_html;type:text" ui:"visible"` // rendered Content, kept in sync by BeforeSave
+	CreatedAt      time.Time      `json:"createdAt" gorm:"column:created_at" ui:"visible;filterable;sortable"`
+	UpdatedAt      time.Time      `json:"updatedAt" gorm:"column:updated_at" ui:"visible;filterable;sortable"`
+	TotalUpVotes   int            `json:"total_up_votes" gorm:"column:total_up_votes" ui:"visible;filterable;sortable"`
+	TotalDownVotes int            `json:"total_down_votes" gorm:"column:total_down_votes" ui:"visible;filterable;sortable"`
+	EditCount      int            `json:"edit_count" gorm:"column:edit_count" ui:"visible;filterable;sortable"`
+	EditedAt       *time.Time     `json:"edited_at" gorm:"column:edited_at" ui:"visible;filterable;sortable"`
+	Edited         bool           `json:"edited" gorm:"-" ui:"visible"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"column:deleted_at;index" ui:"visible;filterable;sortable"`
+	Moderation     string         `json:"moderation" gorm:"column:moderation;size:20;not null;default:'';index" ui:"visible;filterable"`
+	UpVotedByMe    bool           `json:"up_voted_by_me" gorm:"-" ui:"visible"`
+	DownVotedByMe  bool           `json:"down_voted_by_me" gorm:"-" ui:"visible"`
+	BookmarkedByMe bool           `json:"bookmarked_by_me" gorm:"-" ui:"visible"`
+	Votes          []CommentVote  `json:"votes" gorm:"foreignKey:CommentID" ui:"visible;visibility;sortable"`
+	Mentions       []Mention      `json:"mentions" gorm:"polymorphic:Source" ui:"visible"` // @username entities in Content
 }
 
 func (c *Comment) BeforeCreate(tx *gorm.DB) error {
@@ -59,20 +167,120 @@ func (c *Comment) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+func (c *Comment) BeforeSave(tx *gorm.DB) error {
+	c.ContentHTML = markdown.Render(c.Content)
+	return nil
+}
+func (c *Comment) AfterFind(tx *gorm.DB) error {
+	c.Edited = c.EditCount > 0
+	return nil
+}
 func (c *Comment) AfterCreate(tx *gorm.DB) error {
+	c.updateThreadComments(tx)
+	c.updateParentReplies(tx)
+	c.reindexThread(tx)
+	if err := SyncMentions(tx, MentionSourceComment, c.ID); err != nil {
+		logrus.Println(err)
+	}
+	if c.Moderation != ModerationVisible {
+		return nil
+	}
+	return AwardCommentPosted(tx.Session(&gorm.Session{NewDB: true}), *c)
+}
+func (c *Comment) AfterUpdate(tx *gorm.DB) error {
+	c.reindexThread(tx)
+	if err := SyncMentions(tx, MentionSourceComment, c.ID); err != nil {
+		logrus.Println(err)
+	}
+	return nil
+}
+func (c *Comment) AfterDelete(tx *gorm.DB) error {
+	if tx.Statement.Unscoped {
+		if err := RemoveMentions(tx, MentionSourceComment, c.ID); err != nil {
+			logrus.Println(err)
+		}
+	} else if err := c.tombstoneReplies(tx); err != nil {
+		logrus.Println(err)
+	}
+	c.updateThreadComments(tx)
+	c.updateParentReplies(tx)
+	c.reindexThread(tx)
+	return nil
+}
+
+// tombstoneReplies soft deletes the replies below a soft deleted comment
+// with its timestamp, so RestoreComment brings back exactly these
+func (c *Comment) tombstoneReplies(tx *gorm.DB) error {
+	tx = tx.Session(&gorm.Session{NewDB: true})
+	parents := []string{c.ID}
+	for len(parents) > 0 {
+		var replies []string
+		if err := tx.Model(&Comment{}).Where("parent_id IN ?", parents).Pluck("id", &replies).Error; err != nil {
+			return err
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		if err := tx.Model(&Comment{}).Where("id IN ?", replies).UpdateColumn("deleted_at", c.DeletedAt).Error; err != nil {
+			return err
+		}
+		parents = replies
+	}
+	return nil
+}
+
+// Recount refreshes the counters the comment is part of, call it when its
+// moderation changes
+func (c *Comment) Recount(tx *gorm.DB) {
+	c.updateThreadComments(tx)
+	c.updateParentReplies(tx)
+}
+
+// updateThreadComments recounts total_comments of the comment's thread and
+// refreshes its ranking, deleted, hidden and held comments do not count
+func (c *Comment) updateThreadComments(tx *gorm.DB) {
 	if err := tx.Exec(`
 			UPDATE threads
 			SET 
 			total_comments = (
 				SELECT COUNT(*)
 				FROM comments
-				WHERE thread_id = ?
+				WHERE thread_id = ? AND deleted_at IS NULL AND moderation = ?
 			)
 			WHERE id = ?
-		`, c.ThreadID, c.ThreadID).Error; err != nil {
+		`, c.ThreadID, ModerationVisible, c.ThreadID).Error; err != nil {
+		logrus.Println(err)
+	}
+	if err := ranking.RefreshThread(tx, c.ThreadID); err != nil {
+		logrus.Println(err)
+	}
+}
+
+// reindexThread queues the search document of the thread, it carries all
+// comment content, and has the comment embedded
+func (c *Comment) reindexThread(tx *gorm.DB) {
+	search.QueueThread(c.ThreadID)
+	embedding.Wake()
+}
+
+// updateParentReplies recounts total_replies of the comment's parent, if any,
+// counting like updateThreadComments. The derived table keeps MySQL from
+// rejecting a self-referencing UPDATE.
+func (c *Comment) updateParentReplies(tx *gorm.DB) {
+	if c.ParentID == nil || *c.ParentID == "" {
+		return
+	}
+	if err := tx.Exec(`
+			UPDATE comments
+			SET 
+			total_replies = (
+				SELECT COUNT(*)
+				FROM (SELECT id FROM comments WHERE parent_id = ? AND deleted_at IS NULL AND moderation = ?) AS replies
+			)
+			WHERE id = ?
+		`, *c.ParentID, ModerationVisible, *c.ParentID).Error; err != nil {
 		logrus.Println(err)
 	}
-	return nil
 }
 
 // TableName overrides the default table name for Comment model
@@ -81,10 +289,11 @@ func (Comment) TableName() string {
 }
 
 type ThreadVote struct {
-	ID       string `json:"id" gorm:"primaryKey;column:id;size:36"`
-	ThreadID string `json:"thread_id" gorm:"column:thread_id;size:36"`
-	UserID   string `json:"user_id" gorm:"column:user_id;size:36"`
-	VoteType string `json:"vote_type" gorm:"column:vote_type;size:10"`
+	ID        string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	ThreadID  string    `json:"thread_id" gorm:"column:thread_id;size:36;uniqueIndex:idx_thread_votes_thread_user"`
+	UserID    string    `json:"user_id" gorm:"column:user_id;size:36;uniqueIndex:idx_thread_votes_thread_user"` // one vote per user, see CastVote
+	VoteType  string    `json:"vote_type" gorm:"column:vote_type;size:10"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;index"`
 }
 
 func (tv *ThreadVote) BeforeCreate(tx *gorm.DB) error {
@@ -100,10 +309,11 @@ func (ThreadVote) TableName() string {
 }
 
 type CommentVote struct {
-	ID        string `json:"id" gorm:"primaryKey;column:id;size:36"`
-	CommentID string `json:"comment_id" gorm:"column:comment_id;size:36"`
-	UserID    string `json:"user_id" gorm:"column:user_id;size:36"`
-	VoteType  string `json:"vote_type" gorm:"column:vote_type;size:10"`
+	ID        string    `json:"id" gorm:"primaryKey;column:id;size:36"`
+	CommentID string    `json:"comment_id" gorm:"column:comment_id;size:36;uniqueIndex:idx_comment_votes_comment_user"`
+	UserID    string    `json:"user_id" gorm:"column:user_id;size:36;uniqueIndex:idx_comment_votes_comment_user"`
+	VoteType  string    `json:"vote_type" gorm:"column:vote_type;size:10"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;index"`
 }
 
 func (cv *CommentVote) BeforeCreate(tx *gorm.DB) error {
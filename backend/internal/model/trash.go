@@ -0,0 +1,205 @@
+package model
+
+import (
+	"time"
+
+	"microblog/backend/internal/ranking"
+	"microblog/backend/internal/search"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RestoreGracePeriod is how long owners can restore a thread or comment they
+// deleted. Moderators can restore from the trash until it is purged.
+const RestoreGracePeriod = 7 * 24 * time.Hour
+
+// TrashRetention is how long deleted threads and comments stay in the trash
+// before PurgeTrash removes them for good
+const TrashRetention = 30 * 24 * time.Hour
+
+// PurgeAt is when a deleted item leaves the trash
+func PurgeAt(deletedAt gorm.DeletedAt) time.Time {
+	return deletedAt.Time.Add(TrashRetention)
+}
+
+// CanRestore reports whether user may restore an item of ownerID deleted at
+// deletedAt. Moderators restore anything, owners only what they deleted
+// themselves.
+func CanRestore(user User, ownerID, moderation string, deletedAt gorm.DeletedAt) bool {
+	if user.IsModerator() {
+		return true
+	}
+	return user.ID == ownerID && moderation != ModerationRemoved && time.Since(deletedAt.Time) <= RestoreGracePeriod
+}
+
+// RestoreThread undeletes a thread and the comments that were deleted with it
+func RestoreThread(db *gorm.DB, thread Thread) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&Comment{}).
+			Where("thread_id = ? AND deleted_at = ?", thread.ID, thread.DeletedAt).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&Thread{}).
+			Where("id = ?", thread.ID).
+			UpdateColumns(map[string]any{"deleted_at": nil, "moderation": restoredModeration(thread.Moderation)}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := search.IndexThread(db, thread.ID); err != nil {
+		logrus.Println(err)
+	}
+	if err := ranking.RefreshThread(db, thread.ID); err != nil {
+		logrus.Println(err)
+	}
+	return nil
+}
+
+// RestoreComment undeletes a comment and the replies that were deleted with
+// it. The thread and parent comment must not be deleted.
+func RestoreComment(db *gorm.DB, comment Comment) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&Comment{}).
+			Where("thread_id = ? AND deleted_at = ?", comment.ThreadID, comment.DeletedAt).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Comment{}).
+			Where("id = ?", comment.ID).
+			UpdateColumn("moderation", restoredModeration(comment.Moderation)).Error
+	})
+	if err != nil {
+		return err
+	}
+	comment.updateThreadComments(db)
+	comment.updateParentReplies(db)
+	comment.reindexThread(db)
+	return nil
+}
+
+// restoredModeration is the moderation state after a restore, undoing a
+// moderator's removal
+func restoredModeration(moderation string) string {
+	if moderation == ModerationRemoved {
+		return ModerationVisible
+	}
+	return moderation
+}
+
+// PurgeTrash permanently deletes the threads and comments deleted before
+// before, with their votes, tags, watches, summaries, mentions, revisions,
+// reports, classifications and notifications, in one transaction
+func PurgeTrash(db *gorm.DB, before time.Time) (threads, comments int64, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var threadIDs []string
+		if err := tx.Unscoped().Model(&Thread{}).
+			Where("deleted_at < ?", before).
+			Pluck("id", &threadIDs).Error; err != nil {
+			return err
+		}
+		var commentIDs []string
+		query := tx.Unscoped().Model(&Comment{}).Where("deleted_at < ?", before)
+		if len(threadIDs) > 0 {
+			query = query.Or("thread_id IN ?", threadIDs)
+		}
+		if err := query.Pluck("id", &commentIDs).Error; err != nil {
+			return err
+		}
+
+		if len(commentIDs) > 0 {
+			if err := purgeDependents(tx, RevisionSourceComment, "comment_id", commentIDs); err != nil {
+				return err
+			}
+			if err := RevokeComments(tx, commentIDs); err != nil {
+				return err
+			}
+			if err := tx.Where("comment_id IN ?", commentIDs).Delete(&CommentVote{}).Error; err != nil {
+				return err
+			}
+			// The hooks only maintain derived data of live rows, skip them
+			result := tx.Session(&gorm.Session{SkipHooks: true}).Unscoped().Where("id IN ?", commentIDs).Delete(&Comment{})
+			if result.Error != nil {
+				return result.Error
+			}
+			comments = result.RowsAffected
+		}
+
+		if len(threadIDs) > 0 {
+			if err := purgeDependents(tx, RevisionSourceThread, "thread_id", threadIDs); err != nil {
+				return err
+			}
+			if err := tx.Where("thread_id IN ?", threadIDs).Delete(&ThreadVote{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("thread_id IN ?", threadIDs).Delete(&ThreadWatch{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("thread_id IN ?", threadIDs).Delete(&ThreadSummary{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM thread_tags WHERE thread_id IN ?", threadIDs).Error; err != nil {
+				return err
+			}
+			for _, id := range threadIDs {
+				if err := search.RemoveThread(tx, id); err != nil {
+					return err
+				}
+			}
+			result := tx.Session(&gorm.Session{SkipHooks: true}).Unscoped().Where("id IN ?", threadIDs).Delete(&Thread{})
+			if result.Error != nil {
+				return result.Error
+			}
+			threads = result.RowsAffected
+		}
+		return nil
+	})
+	return threads, comments, err
+}
+
+// purgeDependents deletes the mentions, revisions, bookmarks, reports,
+// classifications and notifications of purged threads or comments
+func purgeDependents(tx *gorm.DB, sourceType, notificationColumn string, ids []string) error {
+	if err := tx.Where("source_type = ? AND source_id IN ?", sourceType, ids).Delete(&Classification{}).Error; err != nil {
+		return err
+	}
+	reports := tx.Model(&Report{}).Select("id").Where("target_type = ? AND target_id IN ?", sourceType, ids)
+	if err := tx.Where("report_id IN (?)", reports).Delete(&ReportEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target_type = ? AND target_id IN ?", sourceType, ids).Delete(&Report{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("source_type = ? AND source_id IN ?", sourceType, ids).Delete(&Mention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("source_type = ? AND source_id IN ?", sourceType, ids).Delete(&Revision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target_type = ? AND target_id IN ?", sourceType, ids).Delete(&Bookmark{}).Error; err != nil {
+		return err
+	}
+	notifications := tx.Model(&Notification{}).Select("id").Where(notificationColumn+" IN ?", ids)
+	if err := tx.Where("notification_id IN (?)", notifications).Delete(&NotificationActor{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(notificationColumn+" IN ?", ids).Delete(&Notification{}).Error
+}
+
+// PurgeService empties the trash of items older than TrashRetention, run it
+// in a goroutine
+func PurgeService(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		threads, comments, err := PurgeTrash(db, time.Now().Add(-TrashRetention))
+		if err != nil {
+			logrus.Errorf("trash: purge: %v", err)
+			continue
+		}
+		if threads > 0 || comments > 0 {
+			logrus.Infof("trash: purged %d threads and %d comments", threads, comments)
+		}
+	}
+}
@@ -34,6 +34,11 @@ type User struct {
 	RoleID             uint           `gorm:"column:role_id;index" json:"role_id"`
 	UserRole           UserRole       `gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user_role" `
 	Role               types.HTML     `gorm:"-" json:"role" ui:"visible;visibility;editable;filterable;sortable;selection:/options?data=role"`
+	SuspendedUntil     *time.Time     `gorm:"column:suspended_until" json:"suspended_until" ui:"visible;visibility;filterable;sortable"` // set with Status suspended, nil suspends indefinitely
+	WarningCount       int            `gorm:"column:warning_count" json:"warning_count" ui:"visible;visibility;filterable;sortable"`
+	FollowerCount      int            `gorm:"column:follower_count" json:"follower_count" ui:"visible;visibility;filterable;sortable"`        // see SetFollow
+	FollowingCount     int            `gorm:"column:following_count" json:"following_count" ui:"visible;visibility;filterable;sortable"`      // users and categories
+	Karma              int            `gorm:"column:karma;not null;default:0;index" json:"karma" ui:"visible;visibility;filterable;sortable"` // sum of the user's ReputationEvent points
 
 	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
@@ -63,6 +68,32 @@ func (u *User) StatusBanned() {
 func (u *User) StatusSuspended() {
 	u.Status = StatusSuspended
 }
+
+// IsSuspended reports whether the user is banned or inside a suspension,
+// suspended users can read but not post
+func (u User) IsSuspended() bool {
+	switch u.Status {
+	case StatusBanned:
+		return true
+	case StatusSuspended:
+		return u.SuspendedUntil == nil || time.Now().Before(*u.SuspendedUntil)
+	}
+	return false
+}
+
+// IsModerator reports whether the user can work the moderation queue
+func (u User) IsModerator() bool {
+	return u.RoleID == RoleSuperAdmin || u.RoleID == RoleModerator
+}
+
+// FindUserByUsername looks a user up by username, ignoring case like
+// @mentions do
+func FindUserByUsername(db *gorm.DB, username string) (User, error) {
+	var user User
+	err := db.Where("LOWER(username) = ?", strings.ToLower(username)).First(&user).Error
+	return user, err
+}
+
 func (m *User) BeforeCreate(tx *gorm.DB) (err error) {
 	if m.ID == "" {
 		m.ID = uuid.New().String()
@@ -18,6 +18,14 @@ type UserRole struct {
 	AbilityRules []UserAbilityRule `gorm:"foreignKey:RoleID;references:ID" json:"ability_rules"`
 }
 
+// Roles seeded by database.AutoMigrateDB
+const (
+	RoleSuperAdmin uint = 1
+	RoleDefault    uint = 2
+	RoleVerified   uint = 3
+	RoleModerator  uint = 4
+)
+
 func (UserRole) TableName() string {
 	return "user_roles"
 }
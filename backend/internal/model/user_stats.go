@@ -0,0 +1,41 @@
+package model
+
+import "gorm.io/gorm"
+
+// UserStats is the public activity of a user, counted over their live content
+// that is not hidden by moderators
+type UserStats struct {
+	Threads           int64 `json:"threads"`
+	Comments          int64 `json:"comments"`
+	ReceivedUpVotes   int64 `json:"received_up_votes"`
+	ReceivedDownVotes int64 `json:"received_down_votes"`
+}
+
+// UserActivity counts the threads and comments of userID and the votes they
+// received
+func UserActivity(db *gorm.DB, userID string) (UserStats, error) {
+	var stats UserStats
+	for _, m := range []struct {
+		model any
+		count *int64
+	}{
+		{&Thread{}, &stats.Threads},
+		{&Comment{}, &stats.Comments},
+	} {
+		var row struct {
+			Count int64
+			Up    int64
+			Down  int64
+		}
+		if err := db.Model(m.model).
+			Select("COUNT(*) AS count, COALESCE(SUM(total_up_votes), 0) AS up, COALESCE(SUM(total_down_votes), 0) AS down").
+			Where("user_id = ? AND moderation = ?", userID, ModerationVisible).
+			Scan(&row).Error; err != nil {
+			return stats, err
+		}
+		*m.count = row.Count
+		stats.ReceivedUpVotes += row.Up
+		stats.ReceivedDownVotes += row.Down
+	}
+	return stats, nil
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"fmt"
+
+	"microblog/backend/internal/ranking"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Vote target types
+const (
+	VoteThread  = "threads"
+	VoteComment = "comments"
+)
+
+// VoteTypes are the stored vote_type of each vote value
+var VoteTypes = map[int]string{
+	1:  "up",
+	0:  "neutral",
+	-1: "down",
+}
+
+// Vote is the outcome of CastVote
+type Vote struct {
+	ID       string `json:"id"`
+	VoteType string `json:"vote_type"`
+	Previous string `json:"previous"` // vote_type before, "" for a first vote
+}
+
+// CastVote sets userID's vote on a thread or comment to value (1, 0 or -1)
+// and moves the up/down totals of the target by the difference, in one
+// transaction. Each step is a single conditional statement, so concurrent
+// votes of the same user cannot count twice whatever the driver: the unique
+// index on (target, user) turns a second insert into a no-op and a vote only
+// changes from the type it was read as.
+func CastVote(db *gorm.DB, targetType, targetID, userID string, value int) (Vote, error) {
+	voteType, ok := VoteTypes[value]
+	if !ok {
+		return Vote{}, fmt.Errorf("vote value must be 1, 0 or -1")
+	}
+	table, column := "thread_votes", "thread_id"
+	if targetType == VoteComment {
+		table, column = "comment_votes", "comment_id"
+	}
+	vote := Vote{VoteType: voteType, Previous: voteType}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		changed := false
+		for _, previous := range []string{"up", "down", "neutral"} {
+			if previous == voteType {
+				continue
+			}
+			res := tx.Table(table).
+				Where(column+" = ? AND user_id = ? AND vote_type = ?", targetID, userID, previous).
+				UpdateColumn("vote_type", voteType)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				vote.Previous, changed = previous, true
+				break
+			}
+		}
+		if !changed {
+			// No vote yet, or already voteType
+			var row any = &ThreadVote{ID: uuid.New().String(), ThreadID: targetID, UserID: userID, VoteType: voteType}
+			if targetType == VoteComment {
+				row = &CommentVote{ID: uuid.New().String(), CommentID: targetID, UserID: userID, VoteType: voteType}
+			}
+			res := tx.Session(&gorm.Session{SkipHooks: true}).
+				Clauses(clause.OnConflict{Columns: []clause.Column{{Name: column}, {Name: "user_id"}}, DoNothing: true}).
+				Create(row)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				vote.Previous, changed = "", true
+			}
+		}
+		if err := tx.Table(table).Select("id").Where(column+" = ? AND user_id = ?", targetID, userID).Scan(&vote.ID).Error; err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+
+		ups, downs := voteDelta(vote.Previous, voteType)
+		if ups != 0 || downs != 0 {
+			if err := tx.Table(targetType).Where("id = ?", targetID).UpdateColumns(map[string]any{
+				"total_up_votes":   gorm.Expr("total_up_votes + ?", ups),
+				"total_down_votes": gorm.Expr("total_down_votes + ?", downs),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if targetType == VoteThread {
+			if err := ranking.RefreshThread(tx, targetID); err != nil {
+				return err
+			}
+		}
+		return SyncVoteReputation(tx, targetType, targetID, userID, voteType)
+	})
+	return vote, err
+}
+
+// voteDelta is how a vote going from previous to next moves the up and down
+// totals
+func voteDelta(previous, next string) (ups, downs int) {
+	count := map[string]int{}
+	count[previous]--
+	count[next]++
+	return count["up"], count["down"]
+}
@@ -0,0 +1,235 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Named ranking modes, usable as ?sort=<mode> on the threads list
+const (
+	Hot           = "hot"
+	Top           = "top"
+	Rising        = "rising"
+	Controversial = "controversial"
+)
+
+// RisingWindow is how long a thread can show up in the rising list
+const RisingWindow = 24 * time.Hour
+
+// epoch anchors the time component of the hot score
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Mode describes a ranking mode for the UI (?schema=true)
+type Mode struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Sort        string   `json:"sort"`              // column sort the mode resolves to
+	Windows     []string `json:"windows,omitempty"` // accepted ?window= values
+	MaxAge      string   `json:"max_age,omitempty"` // only threads younger than this are listed
+	maxAge      time.Duration
+}
+
+// Windows are the ?window= values of windowed modes, "all" means no limit
+var Windows = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+var windowNames = []string{"hour", "day", "week", "month", "year", "all"}
+
+// Modes lists every ranking mode in display order
+var Modes = []Mode{
+	{
+		Name:        Hot,
+		Description: "Net votes and comments with time decay, newer threads need fewer votes",
+		Sort:        "-hot_score",
+	},
+	{
+		Name:        Top,
+		Description: "Highest net votes, optionally limited to threads created within a window",
+		Sort:        "-score,-created_at",
+		Windows:     windowNames,
+	},
+	{
+		Name:        Rising,
+		Description: "Young threads gaining votes and comments quickly",
+		Sort:        "-rising_score,-created_at",
+		MaxAge:      RisingWindow.String(),
+		maxAge:      RisingWindow,
+	},
+	{
+		Name:        Controversial,
+		Description: "Many votes split evenly between up and down",
+		Sort:        "-controversial_score,-created_at",
+		Windows:     windowNames,
+	},
+}
+
+// Lookup returns the mode called name
+func Lookup(name string) (Mode, bool) {
+	for _, m := range Modes {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Mode{}, false
+}
+
+// Since returns the oldest creation time a thread may have to be listed in
+// mode m with the given window, zero when there is no limit
+func (m Mode) Since(window string, now time.Time) (time.Time, error) {
+	if m.maxAge > 0 {
+		return now.Add(-m.maxAge), nil
+	}
+	if window == "" || len(m.Windows) == 0 {
+		return time.Time{}, nil
+	}
+	d, ok := Windows[window]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid window '%s', use one of %v", window, windowNames)
+	}
+	if d == 0 {
+		return time.Time{}, nil
+	}
+	return now.Add(-d), nil
+}
+
+// Scores are the stored ranking columns of a thread
+type Scores struct {
+	Score              int     `gorm:"column:score"`
+	HotScore           float64 `gorm:"column:hot_score"`
+	RisingScore        float64 `gorm:"column:rising_score"`
+	ControversialScore float64 `gorm:"column:controversial_score"`
+}
+
+// Compute derives all scores from a thread's counters
+func Compute(ups, downs, comments int, createdAt, now time.Time) Scores {
+	net := ups - downs
+	return Scores{
+		Score:              net,
+		HotScore:           hotScore(net, comments, createdAt),
+		RisingScore:        risingScore(net, ups, comments, createdAt, now),
+		ControversialScore: controversialScore(ups, downs),
+	}
+}
+
+// hotScore grows with the log of net votes and linearly with creation time,
+// so a thread 12.5 hours newer is worth ten times the votes. It does not
+// change as the thread ages, only when its counters do.
+func hotScore(net, comments int, createdAt time.Time) float64 {
+	order := math.Log10(math.Max(math.Abs(float64(net)), 1))
+	sign := 0.0
+	if net > 0 {
+		sign = 1
+	} else if net < 0 {
+		sign = -1
+	}
+	seconds := createdAt.Sub(epoch).Seconds()
+	return sign*order + 0.5*math.Log10(1+float64(comments)) + seconds/45000
+}
+
+// risingScore is recent activity per hour of age, zero once the thread is
+// older than RisingWindow
+func risingScore(net, ups, comments int, createdAt, now time.Time) float64 {
+	age := now.Sub(createdAt)
+	if age > RisingWindow {
+		return 0
+	}
+	activity := float64(ups + 2*comments)
+	if net < 0 {
+		activity += float64(net)
+	}
+	if activity <= 0 {
+		return 0
+	}
+	return activity / math.Pow(math.Max(age.Hours(), 0)+2, 1.5)
+}
+
+// controversialScore is the vote volume raised to the up/down balance, a
+// thread needs both kinds of votes to score at all
+func controversialScore(ups, downs int) float64 {
+	if ups <= 0 || downs <= 0 {
+		return 0
+	}
+	balance := float64(min(ups, downs)) / float64(max(ups, downs))
+	return math.Pow(float64(ups+downs), balance)
+}
+
+type counters struct {
+	ID             string
+	TotalUpVotes   int
+	TotalDownVotes int
+	TotalComments  int
+	CreatedAt      time.Time
+}
+
+// RefreshThread recomputes the stored scores of one thread from its current
+// counters, call it whenever votes or comments change
+func RefreshThread(tx *gorm.DB, threadID string) error {
+	// Called from model hooks, start a fresh statement on the same connection
+	tx = tx.Session(&gorm.Session{NewDB: true})
+
+	var rows []counters
+	if err := tx.Table("threads").
+		Select("id, total_up_votes, total_down_votes, total_comments, created_at").
+		Where("id = ?", threadID).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	return store(tx, rows, time.Now())
+}
+
+// Backfill scores threads that were created before the score columns existed
+func Backfill(db *gorm.DB) error {
+	return refreshWhere(db, db.Where("hot_score = ?", 0))
+}
+
+// RefreshRising recomputes threads whose rising score moves with time: the
+// ones inside RisingWindow and the ones that just left it
+func RefreshRising(db *gorm.DB) error {
+	return refreshWhere(db, db.Where("created_at >= ? OR rising_score <> ?", time.Now().Add(-RisingWindow), 0))
+}
+
+// RefreshService keeps the time based scores fresh, run it in a goroutine
+func RefreshService(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := RefreshRising(db); err != nil {
+			logrus.Errorf("ranking: refresh: %v", err)
+		}
+	}
+}
+
+func refreshWhere(db *gorm.DB, cond *gorm.DB) error {
+	var rows []counters
+	return db.Table("threads").
+		Select("id, total_up_votes, total_down_votes, total_comments, created_at").
+		Where(cond).
+		FindInBatches(&rows, 500, func(tx *gorm.DB, batch int) error {
+			return store(db, rows, time.Now())
+		}).Error
+}
+
+func store(tx *gorm.DB, rows []counters, now time.Time) error {
+	for _, r := range rows {
+		s := Compute(r.TotalUpVotes, r.TotalDownVotes, r.TotalComments, r.CreatedAt, now)
+		if err := tx.Table("threads").Where("id = ?", r.ID).UpdateColumns(map[string]any{
+			"score":               s.Score,
+			"hot_score":           s.HotScore,
+			"rising_score":        s.RisingScore,
+			"controversial_score": s.ControversialScore,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
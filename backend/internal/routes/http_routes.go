@@ -4,7 +4,11 @@ import (
 	"microblog/backend/internal/database"
 	"microblog/backend/internal/handler"
 	"microblog/backend/internal/helper"
+	"microblog/backend/internal/leaderboard"
+	"microblog/backend/internal/middleware"
 	"microblog/backend/internal/model"
+	"microblog/backend/internal/spam"
+	"microblog/backend/internal/stream"
 	"microblog/backend/pkg/util"
 	"net/http"
 	"time"
@@ -12,6 +16,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 var R *gin.Engine
@@ -21,7 +26,7 @@ func Routes() {
 	// model.User endpoints
 	backendAPI := R.Group(util.GetPathOnly(util.Getenv("VITE_BACKEND", "/api")))
 	backendAPI.GET("/options", handler.GetOptions())
-	backendAPI.Any("/auth/login", handler.GetAuthLogin())
+	backendAPI.Any("/auth/login", middleware.RateLimit(loginLimit), handler.GetAuthLogin())
 	backendAPI.GET("/auth/logout", handler.GetAuthLogout())
 	backendAPI.GET("/auth/verify", handler.VerifyAuth())      // Test auth endpoint
 	backendAPI.GET("/google-fonts", handler.GetGoogleFonts()) // Google Fonts list
@@ -35,28 +40,97 @@ func Routes() {
 	// backendAPI.POST("/login", LoginHandler)
 	backendAPI.GET("/users", handler.GET_DEFAULT_TABLE(database.DB, &model.User{}, []string{"UserRole"}))
 	backendAPI.Any("/users/me", GetOwnProfileHandler)
+	backendAPI.GET("/users/mentions", handler.GET_USERS_MENTIONS_HANDLER(database.DB))
+	backendAPI.GET("/users/:username", handler.GET_USERS_USERNAME_HANDLER(database.DB))
+	backendAPI.GET("/users/:username/threads", handler.GET_USERS_USERNAME_THREADS_HANDLER(database.DB, []string{"Tags", "Mentions"}))
+	backendAPI.GET("/users/:username/comments", handler.GET_USERS_USERNAME_COMMENTS_HANDLER(database.DB, []string{"Mentions"}))
+	backendAPI.GET("/users/:username/reputation", handler.GET_USERS_ID_REPUTATION_HANDLER(database.DB))
+	// Real-time events (SSE, or WebSocket on upgrade)
+	backendAPI.GET("/stream", handler.GET_STREAM_HANDLER(stream.Default))
+
+	// Notifications
+	backendAPI.GET("/notifications", handler.GET_NOTIFICATIONS_HANDLER(database.DB))
+	backendAPI.POST("/notifications/read", handler.POST_NOTIFICATIONS_READ_HANDLER(database.DB))
+	backendAPI.PUT("/threads/:threadId/watch", handler.PUT_THREADS_ID_WATCH_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/watch", handler.PUT_THREADS_ID_WATCH_HANDLER(database.DB, false))
+
+	// Category endpoints
+	backendAPI.GET("/categories", handler.GET_CATEGORIES_HANDLER(database.DB))
+	backendAPI.POST("/categories", handler.POST_CATEGORIES_HANDLER(database.DB))
+	backendAPI.PUT("/categories/:slug", handler.PUT_CATEGORIES_SLUG_HANDLER(database.DB))
+	backendAPI.DELETE("/categories/:slug", handler.DELETE_CATEGORIES_SLUG_HANDLER(database.DB))
+
 	// Thread endpoints
-	backendAPI.GET("/threads", handler.GET_THREADS_HANDLER(database.DB, []string{"User"}))
-	backendAPI.POST("/threads", CreateThreadHandler)
+	backendAPI.GET("/threads", handler.GET_THREADS_HANDLER(database.DB, []string{"User", "Tags", "Mentions"}))
+	backendAPI.POST("/threads", middleware.RateLimit(createThreadLimit), CreateThreadHandler)
+	backendAPI.POST("/threads/suggest", middleware.RateLimit(suggestLimit), handler.POST_THREADS_SUGGEST_HANDLER(database.DB))
 	backendAPI.GET("/threads/:threadId", GetThreadDetailHandler)
-	backendAPI.POST("/threads/:threadId/up-vote", UpVoteThreadHandler)
-	backendAPI.POST("/threads/:threadId/down-vote", DownVoteThreadHandler)
-	backendAPI.POST("/threads/:threadId/neutral-vote", NeutralVoteThreadHandler)
+	backendAPI.GET("/search/semantic", middleware.RateLimit(semanticSearchLimit), handler.GET_SEARCH_SEMANTIC_HANDLER(database.DB))
+	backendAPI.GET("/threads/:threadId/summary", handler.GET_THREADS_ID_SUMMARY_HANDLER(database.DB, summaryLimit))
+	backendAPI.POST("/threads/:threadId/up-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_VOTE_HANDLER(database.DB, 1))
+	backendAPI.POST("/threads/:threadId/down-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_VOTE_HANDLER(database.DB, -1))
+	backendAPI.POST("/threads/:threadId/neutral-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_VOTE_HANDLER(database.DB, 0))
+	backendAPI.PUT("/threads/:threadId/vote", middleware.RateLimit(voteLimit), handler.PUT_THREADS_ID_VOTE_HANDLER(database.DB))
 
 	// Comment vote endpoints
-	backendAPI.GET("/threads/:threadId/comments", handler.GET_THREADS_ID_COMMENTS_HANDLER(database.DB, []string{"User"}))
-	backendAPI.POST("/threads/:threadId/comments", CreateThreadCommentHandler)
-	backendAPI.POST("/threads/:threadId/comments/:commentId/up-vote", UpVoteCommentHandler)
-	backendAPI.POST("/threads/:threadId/comments/:commentId/down-vote", DownVoteCommentHandler)
-	backendAPI.POST("/threads/:threadId/comments/:commentId/neutral-vote", NeutralVoteCommentHandler)
+	backendAPI.GET("/threads/:threadId/comments", handler.GET_THREADS_ID_COMMENTS_HANDLER(database.DB, []string{"User", "Mentions"}))
+	backendAPI.POST("/threads/:threadId/comments", middleware.RateLimit(createCommentLimit), CreateThreadCommentHandler)
+	backendAPI.GET("/threads/:threadId/comments/:commentId", handler.GET_THREADS_ID_COMMENTS_ID_HANDLER(database.DB, []string{"User", "Mentions"}))
+	backendAPI.POST("/threads/:threadId/comments/:commentId/replies", middleware.RateLimit(createCommentLimit), handler.POST_THREADS_ID_COMMENTS_ID_REPLIES_HANDLER(database.DB))
+	backendAPI.POST("/threads/:threadId/comments/:commentId/up-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_COMMENTS_ID_VOTE_HANDLER(database.DB, 1))
+	backendAPI.POST("/threads/:threadId/comments/:commentId/down-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_COMMENTS_ID_VOTE_HANDLER(database.DB, -1))
+	backendAPI.POST("/threads/:threadId/comments/:commentId/neutral-vote", middleware.RateLimit(voteLimit), handler.POST_THREADS_ID_COMMENTS_ID_VOTE_HANDLER(database.DB, 0))
+	backendAPI.PUT("/comments/:commentId/vote", middleware.RateLimit(voteLimit), handler.PUT_COMMENTS_ID_VOTE_HANDLER(database.DB))
+	backendAPI.PUT("/threads/:threadId/comments/:commentId/accept", handler.PUT_THREADS_ID_COMMENTS_ID_ACCEPT_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/comments/:commentId/accept", handler.PUT_THREADS_ID_COMMENTS_ID_ACCEPT_HANDLER(database.DB, false))
 
 	// CRUD endpoints for threads
 	backendAPI.PUT("/threads/:threadId", UpdateThreadHandler)
 	backendAPI.DELETE("/threads/:threadId", DeleteThreadHandler)
+	backendAPI.PUT("/threads/:threadId/lock", handler.PUT_THREADS_ID_LOCK_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/lock", handler.PUT_THREADS_ID_LOCK_HANDLER(database.DB, false))
+	backendAPI.PUT("/threads/:threadId/pin", handler.PUT_THREADS_ID_PIN_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/pin", handler.PUT_THREADS_ID_PIN_HANDLER(database.DB, false))
+	backendAPI.PUT("/threads/:threadId/feature", handler.PUT_THREADS_ID_FEATURE_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/feature", handler.PUT_THREADS_ID_FEATURE_HANDLER(database.DB, false))
+	backendAPI.POST("/threads/:threadId/restore", handler.POST_THREADS_ID_RESTORE_HANDLER(database.DB))
+	backendAPI.GET("/threads/:threadId/revisions", handler.GET_THREADS_ID_REVISIONS_HANDLER(database.DB))
+	backendAPI.GET("/threads/:threadId/revisions/diff", handler.GET_THREADS_ID_REVISIONS_DIFF_HANDLER(database.DB))
 
 	// CRUD endpoints for comments
 	backendAPI.PUT("/threads/:threadId/comments/:commentId", UpdateCommentHandler)
 	backendAPI.DELETE("/threads/:threadId/comments/:commentId", DeleteCommentHandler)
+	backendAPI.POST("/threads/:threadId/comments/:commentId/restore", handler.POST_THREADS_ID_COMMENTS_ID_RESTORE_HANDLER(database.DB))
+	backendAPI.GET("/threads/:threadId/comments/:commentId/revisions", handler.GET_THREADS_ID_COMMENTS_ID_REVISIONS_HANDLER(database.DB))
+	backendAPI.GET("/threads/:threadId/comments/:commentId/revisions/diff", handler.GET_THREADS_ID_COMMENTS_ID_REVISIONS_DIFF_HANDLER(database.DB))
+
+	// Deleted threads and comments, for admins
+	backendAPI.GET("/trash", handler.GET_TRASH_HANDLER(database.DB))
+
+	// Follows and the following feed
+	backendAPI.PUT("/users/:username/follow", middleware.RateLimit(followLimit), handler.PUT_USERS_USERNAME_FOLLOW_HANDLER(database.DB, true))
+	backendAPI.DELETE("/users/:username/follow", middleware.RateLimit(followLimit), handler.PUT_USERS_USERNAME_FOLLOW_HANDLER(database.DB, false))
+	backendAPI.PUT("/categories/:slug/follow", middleware.RateLimit(followLimit), handler.PUT_CATEGORIES_SLUG_FOLLOW_HANDLER(database.DB, true))
+	backendAPI.DELETE("/categories/:slug/follow", middleware.RateLimit(followLimit), handler.PUT_CATEGORIES_SLUG_FOLLOW_HANDLER(database.DB, false))
+	backendAPI.GET("/feed", handler.GET_FEED_HANDLER(database.DB, []string{"User", "Tags", "Mentions"}))
+
+	// Bookmarks, private to their owner
+	backendAPI.PUT("/threads/:threadId/bookmark", handler.PUT_THREADS_ID_BOOKMARK_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/bookmark", handler.PUT_THREADS_ID_BOOKMARK_HANDLER(database.DB, false))
+	backendAPI.PUT("/threads/:threadId/comments/:commentId/bookmark", handler.PUT_THREADS_ID_COMMENTS_ID_BOOKMARK_HANDLER(database.DB, true))
+	backendAPI.DELETE("/threads/:threadId/comments/:commentId/bookmark", handler.PUT_THREADS_ID_COMMENTS_ID_BOOKMARK_HANDLER(database.DB, false))
+	backendAPI.GET("/me/bookmarks", handler.GET_ME_BOOKMARKS_HANDLER(database.DB, []string{"User", "Tags", "Mentions"}))
+	backendAPI.GET("/me/bookmarks/comments", handler.GET_ME_BOOKMARKS_COMMENTS_HANDLER(database.DB, []string{"User", "Mentions"}))
+	backendAPI.GET("/me/bookmarks/collections", handler.GET_ME_BOOKMARKS_COLLECTIONS_HANDLER(database.DB))
+	backendAPI.POST("/me/bookmarks/collections", handler.POST_ME_BOOKMARKS_COLLECTIONS_HANDLER(database.DB))
+	backendAPI.DELETE("/me/bookmarks/collections/:collectionId", handler.DELETE_ME_BOOKMARKS_COLLECTIONS_ID_HANDLER(database.DB))
+
+	// Reports and the moderation queue
+	backendAPI.POST("/threads/:threadId/report", middleware.RateLimit(reportLimit), handler.POST_THREADS_ID_REPORT_HANDLER(database.DB))
+	backendAPI.POST("/comments/:commentId/report", middleware.RateLimit(reportLimit), handler.POST_COMMENTS_ID_REPORT_HANDLER(database.DB))
+	backendAPI.GET("/moderation/reports", handler.GET_MODERATION_REPORTS_HANDLER(database.DB))
+	backendAPI.POST("/moderation/reports/:reportId", handler.POST_MODERATION_REPORTS_ID_HANDLER(database.DB))
+	backendAPI.GET("/moderation/classifications", handler.GET_MODERATION_CLASSIFICATIONS_HANDLER(database.DB))
 
 	// Leaderboard
 	backendAPI.GET("/leaderboards", GetLeaderboardsHandler)
@@ -159,20 +233,17 @@ func GetOwnProfileHandler(c *gin.Context) {
 
 // Create thread
 func CreateThreadHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
 		return
 	}
 
 	// Parse request
 	type CreateThreadRequest struct {
-		Title    string `json:"title" binding:"required"`
-		Body     string `json:"body" binding:"required"`
-		Category string `json:"category" binding:"required"`
+		Title    string   `json:"title" binding:"required"`
+		Body     string   `json:"body" binding:"required"`
+		Category string   `json:"category" binding:"required"`
+		Tags     []string `json:"tags"`
 	}
 	var req CreateThreadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -185,49 +256,54 @@ func CreateThreadHandler(c *gin.Context) {
 		return
 	}
 
-	// Check daily thread limit (100 threads per day)
-	var threadCount int64
-	today := time.Now()
-	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
-
-	if err := database.DB.Model(&model.Thread{}).
-		Where("user_id = ? AND created_at >= ? AND created_at < ?", user.ID, startOfDay, endOfDay).
-		Count(&threadCount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// Category must be an existing, non archived category
+	var category model.Category
+	if err := database.DB.Where("slug = ? AND archived = ?", util.Slugify(req.Category), false).First(&category).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   err.Error(),
-			"message": "failed to check thread count",
+			"error":   "unknown category",
+			"message": "category '" + req.Category + "' does not exist",
 			"data":    gin.H{},
 		})
 		return
 	}
-
-	if threadCount >= 100 {
-		c.JSON(http.StatusTooManyRequests, gin.H{
+	if _, err := model.ParseTags(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "daily limit exceeded",
-			"message": "you have reached the maximum limit of 100 threads per day",
-			"data": gin.H{
-				"daily_limit":   100,
-				"current_count": threadCount,
-			},
+			"error":   err.Error(),
+			"message": err.Error(),
+			"data":    gin.H{},
 		})
 		return
 	}
 
-	// Create thread object
-	thread := model.Thread{
-		ID:        uuid.New().String(),
-		Title:     req.Title,
-		Body:      req.Body,
-		Category:  req.Category,
-		CreatedAt: time.Now(),
-		UserID:    user.ID,
+	// Spam checks, a held thread is only shown to its author and moderators
+	moderation, checks, ok := helper.CheckContent(c, user, spam.Post{Type: "threads", Title: req.Title, Body: req.Body})
+	if !ok {
+		return
 	}
 
-	// Save to DB
-	if err := database.DB.Create(&thread).Error; err != nil {
+	// Create thread object
+	thread := model.Thread{
+		ID:         uuid.New().String(),
+		Title:      req.Title,
+		Body:       req.Body,
+		Category:   category.Slug,
+		CreatedAt:  time.Now(),
+		UserID:     user.ID,
+		Moderation: moderation,
+	}
+
+	// Save to DB, tags are only created along with a thread that passed the checks
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := model.FindOrCreateTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		thread.Tags = tags
+		return tx.Create(&thread).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   err.Error(),
@@ -236,6 +312,41 @@ func CreateThreadHandler(c *gin.Context) {
 		})
 		return
 	}
+	helper.ClassifyContent(user, model.Report{
+		TargetType:   model.ReportTargetThread,
+		TargetID:     thread.ID,
+		ThreadID:     thread.ID,
+		TargetUserID: user.ID,
+	}, thread.Category, thread.Title+"\n\n"+thread.Body)
+
+	if moderation == model.ModerationHeld {
+		helper.HoldForReview(model.Report{
+			TargetType:   model.ReportTargetThread,
+			TargetID:     thread.ID,
+			ThreadID:     thread.ID,
+			TargetUserID: user.ID,
+		}, checks)
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"message": "thread held for review",
+			"data":    thread,
+			"checks":  checks,
+		})
+		return
+	}
+
+	stream.Publish(stream.ThreadCreated, thread.ID, thread.Category, gin.H{
+		"id":         thread.ID,
+		"title":      thread.Title,
+		"category":   thread.Category,
+		"tags":       thread.Tags,
+		"created_at": thread.CreatedAt,
+		"owner": gin.H{
+			"id":     user.ID,
+			"name":   user.Name,
+			"avatar": user.Avatar,
+		},
+	})
 
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
@@ -246,12 +357,8 @@ func CreateThreadHandler(c *gin.Context) {
 func UpdateThreadHandler(c *gin.Context) {
 	threadID := c.Param("threadId")
 
-	userData, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+	userData, ok := helper.GetPostingUser(c)
+	if !ok {
 		return
 	}
 
@@ -277,9 +384,10 @@ func UpdateThreadHandler(c *gin.Context) {
 	}
 
 	type UpdateThreadRequest struct {
-		Title    string `json:"title"`
-		Body     string `json:"body"`
-		Category string `json:"category"`
+		Title    string    `json:"title"`
+		Body     string    `json:"body"`
+		Category string    `json:"category"`
+		Tags     *[]string `json:"tags"` // replaces all tags when present
 	}
 	var req UpdateThreadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -292,6 +400,7 @@ func UpdateThreadHandler(c *gin.Context) {
 		return
 	}
 
+	original := thread
 	updated := false
 	if req.Title != "" {
 		thread.Title = req.Title
@@ -302,12 +411,69 @@ func UpdateThreadHandler(c *gin.Context) {
 		updated = true
 	}
 	if req.Category != "" {
-		thread.Category = req.Category
+		var category model.Category
+		if err := database.DB.Where("slug = ? AND archived = ?", util.Slugify(req.Category), false).First(&category).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "unknown category",
+				"message": "category '" + req.Category + "' does not exist",
+				"data":    gin.H{},
+			})
+			return
+		}
+		thread.Category = category.Slug
 		updated = true
 	}
-	if updated {
-		thread.UpdatedAt = time.Now()
-		if err := database.DB.Save(&thread).Error; err != nil {
+	if req.Tags != nil {
+		if _, err := model.ParseTags(*req.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"message": err.Error(),
+				"data":    gin.H{},
+			})
+			return
+		}
+	}
+	if updated || req.Tags != nil {
+		now := time.Now()
+		if updated {
+			thread.UpdatedAt = now
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Tags change along with the edit, a failed save keeps the old ones
+			if req.Tags != nil {
+				tags, err := model.FindOrCreateTags(tx, *req.Tags)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&thread).Association("Tags").Replace(tags); err != nil {
+					return err
+				}
+				thread.Tags = tags
+			}
+			if !updated {
+				return nil
+			}
+			// Only title, body and category changes are edits, tags are not versioned
+			if thread.Title != original.Title || thread.Body != original.Body || thread.Category != original.Category {
+				version, err := model.RecordRevision(tx,
+					model.ThreadRevision(original, original.UserID, original.CreatedAt),
+					model.ThreadRevision(thread, userData.ID, now))
+				if err != nil {
+					return err
+				}
+				thread.EditCount = version - 1
+				thread.EditedAt = &now
+				thread.Edited = true
+			}
+			// Only the edited columns: a full save would write back the
+			// counters and moderation state read before the row lock
+			return tx.Model(&thread).
+				Select("title", "body", "body_html", "category", "edit_count", "edited_at", "updated_at").
+				Updates(&thread).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error":   err.Error(),
@@ -323,13 +489,18 @@ func UpdateThreadHandler(c *gin.Context) {
 		"message": "thread updated",
 		"data": gin.H{
 			"thread": gin.H{
-				"id":        thread.ID,
-				"title":     thread.Title,
-				"body":      thread.Body,
-				"category":  thread.Category,
-				"createdAt": thread.CreatedAt.Format(time.RFC3339),
-				"updatedAt": thread.UpdatedAt.Format(time.RFC3339),
-				"userId":    thread.UserID,
+				"id":         thread.ID,
+				"title":      thread.Title,
+				"body":       thread.Body,
+				"body_html":  thread.BodyHTML,
+				"edited":     thread.Edited,
+				"edit_count": thread.EditCount,
+				"editedAt":   thread.EditedAt,
+				"category":   thread.Category,
+				"tags":       thread.Tags,
+				"createdAt":  thread.CreatedAt.Format(time.RFC3339),
+				"updatedAt":  thread.UpdatedAt.Format(time.RFC3339),
+				"userId":     thread.UserID,
 			},
 		},
 	})
@@ -378,22 +549,24 @@ func DeleteThreadHandler(c *gin.Context) {
 		return
 	}
 
+	stream.Publish(stream.ThreadDeleted, thread.ID, thread.Category, gin.H{
+		"id": thread.ID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "thread deleted",
-		"data":    gin.H{},
+		"data": gin.H{
+			"restore_until": thread.DeletedAt.Time.Add(model.RestoreGracePeriod),
+		},
 	})
 }
 
 func UpdateCommentHandler(c *gin.Context) {
 	threadID := c.Param("threadId")
 	commentID := c.Param("commentId")
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
 		return
 	}
 
@@ -432,10 +605,27 @@ func UpdateCommentHandler(c *gin.Context) {
 		return
 	}
 
-	if req.Content != "" {
+	if req.Content != "" && req.Content != comment.Content {
+		original := comment
+		now := time.Now()
 		comment.Content = req.Content
-		comment.UpdatedAt = time.Now()
-		if err := database.DB.Save(&comment).Error; err != nil {
+		comment.UpdatedAt = now
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			version, err := model.RecordRevision(tx,
+				model.CommentRevision(original, original.UserID, original.CreatedAt),
+				model.CommentRevision(comment, user.ID, now))
+			if err != nil {
+				return err
+			}
+			comment.EditCount = version - 1
+			comment.EditedAt = &now
+			comment.Edited = true
+			// Only the edited columns, see UpdateThreadHandler
+			return tx.Model(&comment).
+				Select("content", "content_html", "edit_count", "edited_at", "updated_at").
+				Updates(&comment).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error":   err.Error(),
@@ -451,12 +641,16 @@ func UpdateCommentHandler(c *gin.Context) {
 		"message": "comment updated",
 		"data": gin.H{
 			"comment": gin.H{
-				"id":        comment.ID,
-				"content":   comment.Content,
-				"createdAt": comment.CreatedAt.Format(time.RFC3339),
-				"updatedAt": comment.UpdatedAt.Format(time.RFC3339),
-				"userId":    comment.UserID,
-				"threadId":  comment.ThreadID,
+				"id":           comment.ID,
+				"content":      comment.Content,
+				"content_html": comment.ContentHTML,
+				"createdAt":    comment.CreatedAt.Format(time.RFC3339),
+				"updatedAt":    comment.UpdatedAt.Format(time.RFC3339),
+				"edited":       comment.Edited,
+				"edit_count":   comment.EditCount,
+				"editedAt":     comment.EditedAt,
+				"userId":       comment.UserID,
+				"threadId":     comment.ThreadID,
 			},
 		},
 	})
@@ -505,10 +699,18 @@ func DeleteCommentHandler(c *gin.Context) {
 		return
 	}
 
+	stream.PublishForThread(database.DB, stream.CommentDeleted, comment.ThreadID, gin.H{
+		"id":        comment.ID,
+		"thread_id": comment.ThreadID,
+		"parent_id": comment.ParentID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "comment deleted",
-		"data":    gin.H{},
+		"data": gin.H{
+			"restore_until": comment.DeletedAt.Time.Add(model.RestoreGracePeriod),
+		},
 	})
 }
 
@@ -516,9 +718,15 @@ func DeleteCommentHandler(c *gin.Context) {
 func GetThreadDetailHandler(c *gin.Context) {
 	threadID := c.Param("threadId")
 	// Preload User and Comments (with their Users)
+	viewer, _ := helper.GetFirebaseUser(c)
 	var thread model.Thread
-	if err := database.DB.Preload("User").
+	if err := database.DB.Scopes(model.Visible("threads", viewer)).
+		Preload("User").
+		Preload("Tags").
+		Preload("Mentions").
+		Preload("Comments", model.Visible("comments", viewer)).
 		Preload("Comments.User").
+		Preload("Comments.Mentions").
 		Preload("Comments.Votes").
 		Preload("Votes").
 		Where("id = ?", threadID).
@@ -530,10 +738,23 @@ func GetThreadDetailHandler(c *gin.Context) {
 		})
 		return
 	}
+	if user := viewer; user != nil {
+		thread.WatchedByMe = model.IsWatching(database.DB, thread, user.ID)
+		thread.BookmarkedByMe = len(model.BookmarkedIDs(database.DB, user.ID, model.BookmarkThread, []string{thread.ID})) > 0
+		var commentIDs []string
+		for _, comment := range thread.Comments {
+			commentIDs = append(commentIDs, comment.ID)
+		}
+		bookmarked := model.BookmarkedIDs(database.DB, user.ID, model.BookmarkComment, commentIDs)
+		for i := range thread.Comments {
+			thread.Comments[i].BookmarkedByMe = bookmarked[thread.Comments[i].ID]
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    thread,
+		"related": handler.RelatedThreads(database.DB, viewer, thread.ID, 5),
 	})
 }
 
@@ -542,9 +763,14 @@ func GetThreadCommentsHandler(c *gin.Context) {
 	// Get thread ID from URL
 	threadID := c.Param("threadId")
 
-	// Find thread
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
+		return
+	}
+
+	// Find thread, one the user cannot see does not exist
 	var thread model.Thread
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
+	if err := database.DB.Scopes(model.Visible("threads", user)).Where("id = ?", threadID).First(&thread).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"error":   err.Error(),
@@ -553,14 +779,6 @@ func GetThreadCommentsHandler(c *gin.Context) {
 		})
 		return
 	}
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
-		return
-	}
 
 	// Parse request
 	type CreateCommentRequest struct {
@@ -602,9 +820,10 @@ func GetThreadCommentsHandler(c *gin.Context) {
 		"message": "comment created",
 		"data": gin.H{
 			"comment": gin.H{
-				"id":        comment.ID,
-				"content":   comment.Content,
-				"createdAt": comment.CreatedAt.Format(time.RFC3339),
+				"id":           comment.ID,
+				"content":      comment.Content,
+				"content_html": comment.ContentHTML,
+				"createdAt":    comment.CreatedAt.Format(time.RFC3339),
 				"owner": gin.H{
 					"id":     user.ID,
 					"name":   user.Name,
@@ -621,9 +840,14 @@ func CreateThreadCommentHandler(c *gin.Context) {
 	// Get thread ID from URL
 	threadID := c.Param("threadId")
 
-	// Find thread
+	user, ok := helper.GetPostingUser(c)
+	if !ok {
+		return
+	}
+
+	// Find thread, one the user cannot see does not exist
 	var thread model.Thread
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
+	if err := database.DB.Scopes(model.Visible("threads", user)).Where("id = ?", threadID).First(&thread).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"error":   err.Error(),
@@ -632,12 +856,7 @@ func CreateThreadCommentHandler(c *gin.Context) {
 		})
 		return
 	}
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+	if !helper.CheckThreadOpen(c, threadID, user) {
 		return
 	}
 
@@ -656,13 +875,19 @@ func CreateThreadCommentHandler(c *gin.Context) {
 		return
 	}
 
+	moderation, checks, ok := helper.CheckContent(c, user, spam.Post{Type: "comments", Body: req.Content})
+	if !ok {
+		return
+	}
+
 	// Create comment object
 	comment := model.Comment{
-		ID:        uuid.New().String(),
-		Content:   req.Content,
-		CreatedAt: time.Now(),
-		UserID:    user.ID,
-		ThreadID:  thread.ID,
+		ID:         uuid.New().String(),
+		Content:    req.Content,
+		CreatedAt:  time.Now(),
+		UserID:     user.ID,
+		ThreadID:   thread.ID,
+		Moderation: moderation,
 	}
 
 	// Save to DB
@@ -675,15 +900,54 @@ func CreateThreadCommentHandler(c *gin.Context) {
 		})
 		return
 	}
+	helper.ClassifyContent(user, model.Report{
+		TargetType:   model.ReportTargetComment,
+		TargetID:     comment.ID,
+		ThreadID:     comment.ThreadID,
+		TargetUserID: user.ID,
+	}, thread.Category, comment.Content)
+
+	message := "comment created"
+	if moderation == model.ModerationHeld {
+		message = "comment held for review"
+		helper.HoldForReview(model.Report{
+			TargetType:   model.ReportTargetComment,
+			TargetID:     comment.ID,
+			ThreadID:     comment.ThreadID,
+			TargetUserID: user.ID,
+		}, checks)
+	} else {
+		if err := model.NotifyComment(database.DB, thread, comment); err != nil {
+			logrus.Println(err)
+		}
+	}
+	// The stream is public, comments on a held or hidden thread stay off it
+	if moderation == model.ModerationVisible && thread.Moderation == model.ModerationVisible {
+		stream.Publish(stream.CommentCreated, thread.ID, thread.Category, gin.H{
+			"id":           comment.ID,
+			"thread_id":    comment.ThreadID,
+			"parent_id":    comment.ParentID,
+			"content":      comment.Content,
+			"content_html": comment.ContentHTML,
+			"createdAt":    comment.CreatedAt.Format(time.RFC3339),
+			"owner": gin.H{
+				"id":     user.ID,
+				"name":   user.Name,
+				"avatar": user.Avatar,
+			},
+		})
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "comment created",
+		"message": message,
 		"data": gin.H{
 			"comment": gin.H{
-				"id":        comment.ID,
-				"content":   comment.Content,
-				"createdAt": comment.CreatedAt.Format(time.RFC3339),
+				"id":           comment.ID,
+				"content":      comment.Content,
+				"content_html": comment.ContentHTML,
+				"createdAt":    comment.CreatedAt.Format(time.RFC3339),
+				"moderation":   comment.Moderation,
 				"owner": gin.H{
 					"id":     user.ID,
 					"name":   user.Name,
@@ -695,587 +959,56 @@ func CreateThreadCommentHandler(c *gin.Context) {
 	})
 }
 
-// Upvote thread
-func UpVoteThreadHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
-		return
-	}
-	// Get thread ID from URL
-	threadID := c.Param("threadId")
-
-	// Validate thread exists
-	var thread model.Thread
-	if err := database.DB.Select("id").Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-	// Check if user already voted
-	var vote model.ThreadVote
-	if err := database.DB.Where("thread_id = ? AND user_id = ?", threadID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "up"
-		vote.VoteType = "up"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.ThreadVote{
-			ID:       uuid.New().String(),
-			ThreadID: threadID,
-			UserID:   user.ID,
-			VoteType: "up",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-
-	}
-	if err := database.DB.Exec(`
-			UPDATE threads
-			SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, threadID, threadID, threadID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread up voted",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.ThreadID,
-			"user_id":          vote.UserID,
-			"vote_type":        "up",
-			"total_up_votes":   thread.TotalUpVotes,
-			"total_down_votes": thread.TotalDownVotes,
-			"total_comments":   thread.TotalComments,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
-
-// Downvote thread
-func DownVoteThreadHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
-		return
-	}
-	// Get thread ID from URL
-	threadID := c.Param("threadId")
-
-	// Validate thread exists
-	var thread model.Thread
-	if err := database.DB.Select("id").Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   err.Error(),
-			"message": "thread not found",
-			"data":    gin.H{},
-		})
-		return
-	}
-
-	// Check if user already voted
-	var vote model.ThreadVote
-	if err := database.DB.Where("thread_id = ? AND user_id = ?", threadID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "down"
-		vote.VoteType = "down"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.ThreadVote{
-			ID:       uuid.New().String(),
-			ThreadID: threadID,
-			UserID:   user.ID,
-			VoteType: "down",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	}
-	if err := database.DB.Exec(`
-			UPDATE threads
-			SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, threadID, threadID, threadID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread down voted",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.ThreadID,
-			"user_id":          vote.UserID,
-			"vote_type":        "down",
-			"total_up_votes":   thread.TotalUpVotes,
-			"total_down_votes": thread.TotalDownVotes,
-			"total_comments":   thread.TotalComments,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
-
-// Neutral vote thread
-func NeutralVoteThreadHandler(c *gin.Context) {
-	// Get thread ID from URL
-	threadID := c.Param("threadId")
-
-	// Validate thread exists
-	var thread model.Thread
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   err.Error(),
-			"message": "thread not found",
-			"data":    gin.H{},
-		})
-		return
-	}
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
+// Get leaderboards
+func GetLeaderboardsHandler(c *gin.Context) {
+	// ?window=day|week|month|all and ?category=<slug>
+	window := c.DefaultQuery("window", "all")
+	if _, ok := leaderboard.Windows[window]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   err.Error(),
+			"error":   "invalid window",
+			"message": "window must be day, week, month or all",
 		})
 		return
 	}
-
-	// Check if user already voted
-	var vote model.ThreadVote
-	if err := database.DB.Where("thread_id = ? AND user_id = ?", threadID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "neutral"
-		vote.VoteType = "neutral"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.ThreadVote{
-			ID:       uuid.New().String(),
-			ThreadID: threadID,
-			UserID:   user.ID,
-			VoteType: "neutral",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+	category := ""
+	if c.Query("category") != "" {
+		category = util.Slugify(c.Query("category"))
+		var exists int64
+		database.DB.Model(&model.Category{}).Where("slug = ?", category).Count(&exists)
+		if exists == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
+				"error":   "unknown category",
+				"message": "category '" + c.Query("category") + "' does not exist",
 			})
 			return
 		}
 	}
-	if err := database.DB.Exec(`
-			UPDATE threads
-			SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM thread_votes
-				WHERE thread_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, threadID, threadID, threadID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", threadID).First(&thread).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread vote neutralized",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.ThreadID,
-			"user_id":          vote.UserID,
-			"vote_type":        "neutral",
-			"total_up_votes":   thread.TotalUpVotes,
-			"total_down_votes": thread.TotalDownVotes,
-			"total_comments":   thread.TotalComments,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
 
-// Upvote comment
-func UpVoteCommentHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
+	board, err := leaderboard.Get(database.DB, window, category)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": err.Error()})
-		return
-	}
-	threadID := c.Param("threadId")
-	commentID := c.Param("commentId")
-	// Validate comment exists
-	var comment model.Comment
-	if err := database.DB.Select("id").Where("id = ? AND thread_id = ?", commentID, threadID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "comment not found", "data": gin.H{}})
-		return
-	}
-
-	// Check if user already voted
-	var vote model.CommentVote
-	if err := database.DB.Where("comment_id = ? AND user_id = ?", commentID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "up"
-		vote.VoteType = "up"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.CommentVote{
-			ID:        uuid.New().String(),
-			CommentID: commentID,
-			UserID:    user.ID,
-			VoteType:  "up",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	}
-	if err := database.DB.Exec(`
-			UPDATE comments SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, commentID, commentID, commentID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread comment up voted",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.CommentID,
-			"user_id":          vote.UserID,
-			"vote_type":        "up",
-			"total_up_votes":   comment.TotalUpVotes,
-			"total_down_votes": comment.TotalDownVotes,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
-
-// Downvote comment
-func DownVoteCommentHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": err.Error()})
-		return
-	}
-	threadID := c.Param("threadId")
-	commentID := c.Param("commentId")
-
-	// Validate comment exists
-	var comment model.Comment
-	if err := database.DB.Select("id").Where("id = ? AND thread_id = ?", commentID, threadID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "comment not found", "data": gin.H{}})
-		return
-	}
-	// Check if user already voted
-	var vote model.CommentVote
-	if err := database.DB.Where("comment_id = ? AND user_id = ?", commentID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "down"
-		vote.VoteType = "down"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.CommentVote{
-			ID:        uuid.New().String(),
-			CommentID: commentID,
-			UserID:    user.ID,
-			VoteType:  "down",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	}
-	if err := database.DB.Exec(`
-			UPDATE comments SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, commentID, commentID, commentID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread comment down voted",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.CommentID,
-			"user_id":          vote.UserID,
-			"vote_type":        "up",
-			"total_up_votes":   comment.TotalUpVotes,
-			"total_down_votes": comment.TotalDownVotes,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
-
-// Neutral vote comment
-func NeutralVoteCommentHandler(c *gin.Context) {
-	user, err := helper.GetFirebaseUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": err.Error()})
-		return
-	}
-	threadID := c.Param("threadId")
-	commentID := c.Param("commentId")
-
-	// Validate comment exists
-	var comment model.Comment
-	if err := database.DB.Select("id").Where("id = ? AND thread_id = ?", commentID, threadID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "comment not found", "data": gin.H{}})
-		return
-	}
-
-	// Check if user already voted
-	var vote model.CommentVote
-	if err := database.DB.Where("comment_id = ? AND user_id = ?", commentID, user.ID).First(&vote).Error; err == nil {
-		// Update voteType to "neutral"
-		vote.VoteType = "neutral"
-		if err := database.DB.Save(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to update vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	} else {
-		// Create new vote
-		vote = model.CommentVote{
-			ID:        uuid.New().String(),
-			CommentID: commentID,
-			UserID:    user.ID,
-			VoteType:  "neutral",
-		}
-		if err := database.DB.Create(&vote).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "failed to create vote",
-				"data":    gin.H{},
-			})
-			return
-		}
-	}
-	if err := database.DB.Exec(`
-			UPDATE comments SET 
-			total_up_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'up'
-			),
-			total_down_votes = (
-				SELECT COUNT(*)
-				FROM comment_votes
-				WHERE comment_id = ? AND vote_type = 'down'
-			)
-			WHERE id = ?
-		`, commentID, commentID, commentID).Error; err != nil {
-		logrus.Println(err)
-	}
-	if err := database.DB.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error(), "message": "thread not found", "data": gin.H{}})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "thread comment neutral voted",
-		"data": gin.H{
-			"id":               vote.ID,
-			"thread_id":        vote.CommentID,
-			"user_id":          vote.UserID,
-			"vote_type":        "up",
-			"total_up_votes":   comment.TotalUpVotes,
-			"total_down_votes": comment.TotalDownVotes,
-			"up_voted_by_me":   true,
-			"down_voted_by_me": false,
-		},
-	})
-}
-
-// Get leaderboards
-func GetLeaderboardsHandler(c *gin.Context) {
-	// Get all users
-	var users []model.User
-	if err := database.DB.Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   err.Error(),
-			"message": "failed to get users",
+			"message": "failed to get leaderboard",
 		})
 		return
 	}
 
-	leaderboards := []gin.H{}
-
-	for _, user := range users {
-		var threadVotesCount int64
-		var commentVotesCount int64
-		var commentsCount int64
-
-		// Count thread votes (up/down only)
-		database.DB.Model(&model.ThreadVote{}).
-			Where("user_id = ? AND vote_type IN ?", user.ID, []string{"up", "down"}).
-			Count(&threadVotesCount)
-
-		// Count comment votes (up/down only)
-		database.DB.Model(&model.CommentVote{}).
-			Where("user_id = ? AND vote_type IN ?", user.ID, []string{"up", "down"}).
-			Count(&commentVotesCount)
-
-		// Count comments
-		database.DB.Model(&model.Comment{}).
-			Where("owner_id = ?", user.ID).
-			Count(&commentsCount)
-
-		score := int(threadVotesCount+commentVotesCount)*5 + int(commentsCount)*20
-
+	leaderboards := make([]gin.H, 0, len(board.Entries))
+	for _, entry := range board.Entries {
 		leaderboards = append(leaderboards, gin.H{
 			"user": gin.H{
-				"id":     user.ID,
-				"name":   user.Name,
-				"email":  user.Email,
-				"avatar": user.Avatar,
+				"id":       entry.UserID,
+				"username": entry.Username,
+				"name":     entry.Name,
+				"email":    entry.Email,
+				"avatar":   entry.Avatar,
 			},
-			"score": score,
+			"votes":    entry.Votes,
+			"comments": entry.Comments,
+			"score":    entry.Score,
 		})
 	}
 
@@ -1283,6 +1016,10 @@ func GetLeaderboardsHandler(c *gin.Context) {
 		"status": "success",
 		"data": gin.H{
 			"leaderboards": leaderboards,
+			"window":       board.Window,
+			"category":     board.Category,
+			"weights":      board.Weights,
+			"generated_at": board.GeneratedAt,
 		},
 	})
 }
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"time"
+
+	"microblog/backend/internal/middleware"
+	"microblog/backend/internal/model"
+)
+
+// staffLimits lifts the limits of moderators and admins
+var staffLimits = map[uint]middleware.Limit{
+	model.RoleSuperAdmin: {},
+	model.RoleModerator:  {},
+}
+
+// Rate limit policies of the write and LLM endpoints, each overridable with
+// RATE_LIMIT_<NAME>, e.g. RATE_LIMIT_THREADS_CREATE=50/24h
+var (
+	loginLimit = middleware.Policy{
+		Name:  "auth.login",
+		By:    middleware.ByIP,
+		Limit: middleware.Limit{Requests: 30, Window: 10 * time.Minute},
+	}
+	createThreadLimit = middleware.Policy{
+		Name:  "threads.create",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 100, Window: 24 * time.Hour},
+		Roles: staffLimits,
+	}
+	createCommentLimit = middleware.Policy{
+		Name:  "comments.create",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 60, Window: time.Hour},
+		Roles: staffLimits,
+	}
+	voteLimit = middleware.Policy{
+		Name:  "votes",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 60, Window: time.Minute},
+		Roles: staffLimits,
+	}
+	reportLimit = middleware.Policy{
+		Name:  "reports",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 20, Window: time.Hour},
+		Roles: staffLimits,
+	}
+	summaryLimit = middleware.Policy{
+		Name:  "threads.summary", // only summaries written, cached ones are free
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 10, Window: 24 * time.Hour},
+		Roles: staffLimits,
+	}
+	suggestLimit = middleware.Policy{
+		Name:  "threads.suggest",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 30, Window: time.Hour},
+		Roles: staffLimits,
+	}
+	semanticSearchLimit = middleware.Policy{
+		Name:  "search.semantic", // every search embeds its query
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 30, Window: time.Minute},
+		Roles: staffLimits,
+	}
+	followLimit = middleware.Policy{
+		Name:  "follows",
+		By:    middleware.ByUser,
+		Limit: middleware.Limit{Requests: 100, Window: time.Hour},
+		Roles: staffLimits,
+	}
+)
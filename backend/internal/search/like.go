@@ -0,0 +1,46 @@
+package search
+
+import (
+	"gorm.io/gorm"
+)
+
+// likeBackend is the fallback for drivers without a supported full-text
+// engine (SQL Server): every term must appear somewhere in the document,
+// title hits rank above body hits, body above comments
+type likeBackend struct{}
+
+func (likeBackend) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Document{})
+}
+
+func (likeBackend) Put(tx *gorm.DB, doc Document) error {
+	if err := RemoveThread(tx, doc.ThreadID); err != nil {
+		return err
+	}
+	return tx.Create(&doc).Error
+}
+
+func (likeBackend) Match(db *gorm.DB, q string) *gorm.DB {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return db.Table(TableName).Select("thread_id, 0 AS search_rank").Where("1 = 0")
+	}
+
+	rank := ""
+	var rankArgs []any
+	query := db.Table(TableName)
+	for i, t := range terms {
+		like := "%" + t + "%"
+		if i > 0 {
+			rank += " + "
+		}
+		rank += "CASE WHEN LOWER(title) LIKE ? THEN 4 ELSE 0 END + CASE WHEN LOWER(body) LIKE ? THEN 2 ELSE 0 END + CASE WHEN LOWER(comments) LIKE ? THEN 1 ELSE 0 END"
+		rankArgs = append(rankArgs, like, like, like)
+		query = query.Where("(LOWER(title) LIKE ? OR LOWER(body) LIKE ? OR LOWER(comments) LIKE ?)", like, like, like)
+	}
+	return query.Select("thread_id, "+rank+" AS search_rank", rankArgs...)
+}
+
+func (likeBackend) Snippets(db *gorm.DB, threadIDs []string, q string) (map[string]string, error) {
+	return highlightDocuments(db, threadIDs, q)
+}
@@ -0,0 +1,52 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// mysqlBackend uses an InnoDB FULLTEXT index in boolean mode
+type mysqlBackend struct{}
+
+func (mysqlBackend) Migrate(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + TableName + ` (
+			thread_id varchar(36) NOT NULL PRIMARY KEY,
+			title text,
+			body mediumtext,
+			comments mediumtext,
+			FULLTEXT KEY ft_` + TableName + ` (title, body, comments)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`).Error
+}
+
+func (mysqlBackend) Put(tx *gorm.DB, doc Document) error {
+	if err := RemoveThread(tx, doc.ThreadID); err != nil {
+		return err
+	}
+	return tx.Create(&doc).Error
+}
+
+func (mysqlBackend) Match(db *gorm.DB, q string) *gorm.DB {
+	query := booleanQuery(q)
+	return db.Table(TableName).
+		Select("thread_id, MATCH(title, body, comments) AGAINST (? IN BOOLEAN MODE) AS search_rank", query).
+		Where("MATCH(title, body, comments) AGAINST (? IN BOOLEAN MODE)", query)
+}
+
+// MySQL has no snippet function, highlight in Go
+func (mysqlBackend) Snippets(db *gorm.DB, threadIDs []string, q string) (map[string]string, error) {
+	return highlightDocuments(db, threadIDs, q)
+}
+
+// booleanQuery requires every term, the last one also matches as a prefix
+func booleanQuery(q string) string {
+	terms := Terms(q)
+	for i, t := range terms {
+		terms[i] = "+" + t
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
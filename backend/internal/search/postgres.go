@@ -0,0 +1,86 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// pgConfig is the text search configuration, "simple" does no stemming so
+// it works the same for every language posted on the forum
+const pgConfig = "simple"
+
+// postgresBackend keeps a weighted tsvector per thread behind a GIN index
+type postgresBackend struct{}
+
+func (postgresBackend) Migrate(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + TableName + ` (
+			thread_id varchar(36) PRIMARY KEY,
+			title text,
+			body text,
+			comments text,
+			document tsvector
+		)`).Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_" + TableName + "_document ON " + TableName + " USING GIN (document)").Error
+}
+
+func (postgresBackend) Put(tx *gorm.DB, doc Document) error {
+	if err := RemoveThread(tx, doc.ThreadID); err != nil {
+		return err
+	}
+	return tx.Exec(`
+		INSERT INTO `+TableName+` (thread_id, title, body, comments, document)
+		VALUES (?, ?, ?, ?,
+			setweight(to_tsvector('`+pgConfig+`', ?), 'A') ||
+			setweight(to_tsvector('`+pgConfig+`', ?), 'B') ||
+			setweight(to_tsvector('`+pgConfig+`', ?), 'C')
+		)`,
+		doc.ThreadID, doc.Title, doc.Body, doc.Comments,
+		doc.Title, doc.Body, doc.Comments,
+	).Error
+}
+
+func (postgresBackend) Match(db *gorm.DB, q string) *gorm.DB {
+	query := tsQuery(q)
+	return db.Table(TableName).
+		Select("thread_id, ts_rank(document, to_tsquery('"+pgConfig+"', ?)) AS search_rank", query).
+		Where("document @@ to_tsquery('"+pgConfig+"', ?)", query)
+}
+
+func (postgresBackend) Snippets(db *gorm.DB, threadIDs []string, q string) (map[string]string, error) {
+	var rows []struct {
+		ThreadID string
+		Snippet  string
+	}
+	options := "StartSel=" + startSel + ", StopSel=" + stopSel + ", MaxWords=35, MinWords=15, MaxFragments=1"
+	if err := db.Table(TableName).
+		Select(
+			"thread_id, ts_headline('"+pgConfig+"', concat_ws(' ', title, body, comments), to_tsquery('"+pgConfig+"', ?), ?) AS snippet",
+			tsQuery(q), options,
+		).
+		Where("thread_id IN ?", threadIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	snippets := make(map[string]string, len(rows))
+	for _, r := range rows {
+		snippets[r.ThreadID] = r.Snippet
+	}
+	return snippets, nil
+}
+
+// tsQuery ANDs the terms together, the last one also matches as a prefix
+func tsQuery(q string) string {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return "''"
+	}
+	for i, t := range terms {
+		terms[i] = "'" + t + "'"
+	}
+	terms[len(terms)-1] += ":*"
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	queueMu sync.Mutex
+	queued  = map[string]struct{}{}
+)
+
+// QueueThread marks the document of a thread stale, ReindexService rebuilds
+// it shortly after. Comment writes use it instead of IndexThread, so a burst
+// of comments on a busy thread rebuilds its document once instead of
+// re-reading every comment on each write.
+func QueueThread(threadID string) {
+	queueMu.Lock()
+	queued[threadID] = struct{}{}
+	queueMu.Unlock()
+}
+
+// Flush rebuilds the documents of the queued threads now. A thread that
+// fails stays queued for the next flush.
+func Flush(db *gorm.DB) error {
+	queueMu.Lock()
+	ids := make([]string, 0, len(queued))
+	for id := range queued {
+		ids = append(ids, id)
+	}
+	queued = map[string]struct{}{}
+	queueMu.Unlock()
+
+	var firstErr error
+	for _, id := range ids {
+		if err := IndexThread(db, id); err != nil {
+			QueueThread(id)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// ReindexService flushes the queued threads every interval, run it in a
+// goroutine
+func ReindexService(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := Flush(db); err != nil {
+			logrus.Errorf("search: reindex: %v", err)
+		}
+	}
+}
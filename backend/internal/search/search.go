@@ -0,0 +1,229 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// TableName is the search document table (or FTS5 virtual table) holding
+// one row per thread: its title, body and all comment content
+const TableName = "thread_search"
+
+// Alias is the name the joined search subquery gets in list queries, it
+// exposes thread_id and search_rank (higher is better)
+const Alias = "search"
+
+// Snippet highlight markers, replaced by <mark></mark> once the snippet
+// text has been HTML escaped
+const (
+	startSel = "\x02"
+	stopSel  = "\x03"
+)
+
+// Document is the indexed text of one thread
+type Document struct {
+	ThreadID string `gorm:"column:thread_id;primaryKey;size:36"`
+	Title    string `gorm:"column:title"`
+	Body     string `gorm:"column:body"`
+	Comments string `gorm:"column:comments"`
+}
+
+func (Document) TableName() string {
+	return TableName
+}
+
+// Backend is a driver specific full-text implementation
+type Backend interface {
+	// Migrate creates the search table and its index
+	Migrate(db *gorm.DB) error
+	// Put replaces the indexed document of a thread
+	Put(tx *gorm.DB, doc Document) error
+	// Match returns a subquery selecting thread_id and search_rank of every
+	// thread matching q
+	Match(db *gorm.DB, q string) *gorm.DB
+	// Snippets returns the best matching fragment of each thread, matches
+	// wrapped in startSel/stopSel
+	Snippets(db *gorm.DB, threadIDs []string, q string) (map[string]string, error)
+}
+
+// For picks the backend matching the database driver
+func For(db *gorm.DB) Backend {
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return sqliteBackend{}
+	case "postgres":
+		return postgresBackend{}
+	case "mysql":
+		return mysqlBackend{}
+	}
+	return likeBackend{}
+}
+
+// Migrate creates the search table and indexes threads that are not in it yet
+func Migrate(db *gorm.DB) error {
+	if err := For(db).Migrate(db); err != nil {
+		return err
+	}
+	var ids []string
+	if err := db.Table("threads").
+		Where("id NOT IN (?) AND deleted_at IS NULL", db.Table(TableName).Select("thread_id")).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := IndexThread(db, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		logrus.Infof("search: indexed %d threads", len(ids))
+	}
+	return nil
+}
+
+// IndexThread rebuilds the search document of a thread from its current
+// title, body and comments. A thread that no longer exists or is deleted is
+// removed.
+func IndexThread(tx *gorm.DB, threadID string) error {
+	// Called from model hooks, start a fresh statement on the same connection
+	tx = tx.Session(&gorm.Session{NewDB: true})
+
+	var doc Document
+	if err := tx.Table("threads").
+		Select("id AS thread_id, title, body").
+		Where("id = ? AND deleted_at IS NULL", threadID).
+		Limit(1).
+		Scan(&doc).Error; err != nil {
+		return err
+	}
+	if doc.ThreadID == "" {
+		return RemoveThread(tx, threadID)
+	}
+
+	var comments []string
+	if err := tx.Table("comments").
+		Where("thread_id = ? AND deleted_at IS NULL", threadID).
+		Order("created_at").
+		Pluck("content", &comments).Error; err != nil {
+		return err
+	}
+	doc.Comments = strings.Join(comments, "\n")
+	return For(tx).Put(tx, doc)
+}
+
+// RemoveThread drops the search document of a thread
+func RemoveThread(tx *gorm.DB, threadID string) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Exec("DELETE FROM "+TableName+" WHERE thread_id = ?", threadID).Error
+}
+
+// Apply joins the matching threads of q onto a threads query, rows that do
+// not match are dropped. Select Alias+".search_rank" to read the ranking.
+func Apply(db *gorm.DB, query *gorm.DB, q string) *gorm.DB {
+	return query.Joins("JOIN (?) AS "+Alias+" ON "+Alias+".thread_id = threads.id", For(db).Match(db, q))
+}
+
+// Snippets returns an HTML safe snippet per thread id with the matching
+// words wrapped in <mark></mark>
+func Snippets(db *gorm.DB, threadIDs []string, q string) map[string]string {
+	if len(threadIDs) == 0 {
+		return map[string]string{}
+	}
+	raw, err := For(db).Snippets(db, threadIDs, q)
+	if err != nil {
+		logrus.Errorf("search: snippets: %v", err)
+		return map[string]string{}
+	}
+	for id, s := range raw {
+		s = html.EscapeString(s)
+		s = strings.ReplaceAll(s, startSel, "<mark>")
+		s = strings.ReplaceAll(s, stopSel, "</mark>")
+		raw[id] = s
+	}
+	return raw
+}
+
+// highlightDocuments builds snippets in Go for backends without a snippet
+// function, looking at the title first, then the body and the comments
+func highlightDocuments(db *gorm.DB, threadIDs []string, q string) (map[string]string, error) {
+	var docs []Document
+	if err := db.Where("thread_id IN ?", threadIDs).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+
+	terms := Terms(q)
+	snippets := make(map[string]string, len(docs))
+	for _, d := range docs {
+		for _, text := range []string{d.Title, d.Body, d.Comments} {
+			if s, ok := highlight(text, terms, 24); ok {
+				snippets[d.ThreadID] = s
+				break
+			}
+		}
+	}
+	return snippets, nil
+}
+
+// Terms splits a free text query into lowercase words, dropping every
+// operator character so user input can never break a MATCH expression
+func Terms(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var terms []string
+	seen := map[string]bool{}
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// highlight returns a window of about width words around the first term
+// found in text, every matching word wrapped in startSel/stopSel
+func highlight(text string, terms []string, width int) (string, bool) {
+	words := strings.Fields(text)
+	first := -1
+	marked := make([]bool, len(words))
+	for i, w := range words {
+		lw := strings.ToLower(w)
+		for _, t := range terms {
+			if strings.Contains(lw, t) {
+				marked[i] = true
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start := max(first-width/3, 0)
+	end := min(start+width, len(words))
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteByte(' ')
+		}
+		if marked[i] {
+			b.WriteString(startSel + words[i] + stopSel)
+		} else {
+			b.WriteString(words[i])
+		}
+	}
+	if end < len(words) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
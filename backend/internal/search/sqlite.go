@@ -0,0 +1,70 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sqliteBackend uses an FTS5 virtual table ranked with bm25
+type sqliteBackend struct{}
+
+func (sqliteBackend) Migrate(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS ` + TableName + ` USING fts5(
+			thread_id UNINDEXED,
+			title,
+			body,
+			comments,
+			tokenize = 'porter unicode61'
+		)`).Error
+}
+
+func (sqliteBackend) Put(tx *gorm.DB, doc Document) error {
+	if err := RemoveThread(tx, doc.ThreadID); err != nil {
+		return err
+	}
+	return tx.Exec(
+		"INSERT INTO "+TableName+" (thread_id, title, body, comments) VALUES (?, ?, ?, ?)",
+		doc.ThreadID, doc.Title, doc.Body, doc.Comments,
+	).Error
+}
+
+// Title matches weigh more than body matches, body more than comments
+func (sqliteBackend) Match(db *gorm.DB, q string) *gorm.DB {
+	return db.Table(TableName).
+		Select("thread_id, -bm25("+TableName+", 0, 10.0, 4.0, 1.0) AS search_rank").
+		Where(TableName+" MATCH ?", ftsQuery(q))
+}
+
+func (sqliteBackend) Snippets(db *gorm.DB, threadIDs []string, q string) (map[string]string, error) {
+	var rows []struct {
+		ThreadID string
+		Snippet  string
+	}
+	if err := db.Table(TableName).
+		Select("thread_id, snippet("+TableName+", -1, ?, ?, '…', 24) AS snippet", startSel, stopSel).
+		Where(TableName+" MATCH ? AND thread_id IN ?", ftsQuery(q), threadIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	snippets := make(map[string]string, len(rows))
+	for _, r := range rows {
+		snippets[r.ThreadID] = r.Snippet
+	}
+	return snippets, nil
+}
+
+// ftsQuery quotes every term so FTS5 treats them as plain words, all terms
+// must match and the last one also matches as a prefix
+func ftsQuery(q string) string {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return `""`
+	}
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
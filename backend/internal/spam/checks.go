@@ -0,0 +1,127 @@
+package spam
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Blocklist rejects posts containing any of Terms, lower case words,
+// phrases or domains
+type Blocklist struct {
+	Terms []string
+}
+
+func (Blocklist) Name() string { return "blocklist" }
+
+func (b Blocklist) Check(db *gorm.DB, post Post) Result {
+	text := strings.ToLower(post.Text())
+	for _, term := range b.Terms {
+		if strings.Contains(text, term) {
+			return Result{Verdict: Reject, Reason: fmt.Sprintf("contains the blocked term %q", term)}
+		}
+	}
+	return Result{}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://\S+|\bwww\.\S+`)
+
+// Links rejects posts with more than Max links and holds posts with three
+// links or more when links make up more than Density of the words
+type Links struct {
+	Max     int
+	Density float64
+}
+
+func (Links) Name() string { return "links" }
+
+func (l Links) Check(db *gorm.DB, post Post) Result {
+	text := post.Text()
+	links := len(linkPattern.FindAllString(text, -1))
+	words := len(strings.Fields(text))
+	switch {
+	case links == 0:
+		return Result{}
+	case l.Max > 0 && links > l.Max:
+		return Result{Verdict: Reject, Reason: fmt.Sprintf("%d links, at most %d are allowed", links, l.Max)}
+	case links >= 3 && float64(links)/float64(words) > l.Density:
+		return Result{Verdict: Hold, Reason: fmt.Sprintf("%d links in %d words", links, words)}
+	}
+	return Result{}
+}
+
+// NewAccount holds posts with links by accounts younger than Age
+type NewAccount struct {
+	Age time.Duration
+}
+
+func (NewAccount) Name() string { return "new_account" }
+
+func (n NewAccount) Check(db *gorm.DB, post Post) Result {
+	if post.UserCreatedAt.IsZero() || time.Since(post.UserCreatedAt) >= n.Age {
+		return Result{}
+	}
+	if !linkPattern.MatchString(post.Text()) {
+		return Result{}
+	}
+	return Result{Verdict: Hold, Reason: "links from an account younger than " + n.Age.String()}
+}
+
+// Duplicate compares a post with the author's Recent threads and comments
+// of the last Within, deleted ones included. One near duplicate holds the
+// post, RejectAt of them reject it. Posts under MinWords words are not
+// checked, short replies repeat naturally.
+type Duplicate struct {
+	Distance int // simhash bits near duplicates differ by at most
+	RejectAt int
+	Recent   int
+	Within   time.Duration
+	MinWords int
+}
+
+func (Duplicate) Name() string { return "duplicate" }
+
+func (d Duplicate) Check(db *gorm.DB, post Post) Result {
+	words := Words(post.Text())
+	if len(words) < d.MinWords {
+		return Result{}
+	}
+	hash := Simhash(words)
+
+	since := time.Now().Add(-d.Within)
+	var threads []struct{ Title, Body string }
+	var recent []string
+	db.Table("threads").
+		Select("title, body").
+		Where("user_id = ? AND created_at > ?", post.UserID, since).
+		Order("created_at desc").
+		Limit(d.Recent).
+		Scan(&threads)
+	for _, t := range threads {
+		recent = append(recent, t.Title+"\n"+t.Body)
+	}
+	var comments []string
+	db.Table("comments").
+		Where("user_id = ? AND created_at > ?", post.UserID, since).
+		Order("created_at desc").
+		Limit(d.Recent).
+		Pluck("content", &comments)
+	recent = append(recent, comments...)
+
+	near := 0
+	for _, text := range recent {
+		if Distance(hash, Simhash(Words(text))) <= d.Distance {
+			near++
+		}
+	}
+	switch {
+	case near == 0:
+		return Result{}
+	case d.RejectAt > 0 && near >= d.RejectAt:
+		return Result{Verdict: Reject, Reason: fmt.Sprintf("repeats %d of your recent posts", near)}
+	}
+	return Result{Verdict: Hold, Reason: fmt.Sprintf("repeats %d of your recent posts", near)}
+}
@@ -0,0 +1,55 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingle is how many words make up one feature of a simhash
+const shingle = 3
+
+// Words splits text into lower case words, dropping punctuation
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Simhash fingerprints words by their overlapping shingles, texts that share
+// most shingles get fingerprints only a few bits apart
+func Simhash(words []string) uint64 {
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	if len(words) < shingle {
+		add(strings.Join(words, " "))
+	}
+	for i := 0; i+shingle <= len(words); i++ {
+		add(strings.Join(words[i:i+shingle], " "))
+	}
+
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// Distance is the number of bits two simhashes differ in
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,115 @@
+// Package spam screens new threads and comments before they are saved. A
+// Pipeline of checks gives each post a verdict: allow it, hold it for a
+// moderator to review, or reject it.
+package spam
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"microblog/backend/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// Verdicts, from least to most severe
+const (
+	Allow  = "allow"
+	Hold   = "hold" // saved, but only the author and moderators see it until reviewed
+	Reject = "reject"
+)
+
+var severity = map[string]int{Allow: 0, Hold: 1, Reject: 2}
+
+// Post is a thread or comment about to be saved
+type Post struct {
+	Type          string // threads | comments
+	UserID        string
+	UserCreatedAt time.Time
+	Title         string // threads only
+	Body          string
+}
+
+// Text is everything the post says
+func (p Post) Text() string {
+	return strings.TrimSpace(p.Title + "\n" + p.Body)
+}
+
+// Result is what a check found, a zero Result allows the post
+type Result struct {
+	Check   string `json:"check"`
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
+}
+
+// Check is one step of a Pipeline
+type Check interface {
+	Name() string
+	Check(db *gorm.DB, post Post) Result
+}
+
+// Pipeline runs its checks in order
+type Pipeline []Check
+
+// Run checks post and returns the most severe verdict with the results that
+// did not allow it. A rejection skips the remaining checks.
+func (p Pipeline) Run(db *gorm.DB, post Post) (string, []Result) {
+	verdict := Allow
+	var found []Result
+	for _, check := range p {
+		res := check.Check(db, post)
+		if res.Verdict == "" || res.Verdict == Allow {
+			continue
+		}
+		res.Check = check.Name()
+		found = append(found, res)
+		if severity[res.Verdict] > severity[verdict] {
+			verdict = res.Verdict
+		}
+		if verdict == Reject {
+			break
+		}
+	}
+	return verdict, found
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultPipeline Pipeline
+)
+
+// Default is the pipeline of new posts, configured from the environment:
+//
+//	SPAM_BLOCKLIST             comma separated terms or domains that reject a post
+//	SPAM_DUPLICATE_DISTANCE    simhash bits two posts may differ by and still be duplicates (12)
+//	SPAM_DUPLICATE_REJECT      near duplicates among recent posts that reject instead of hold (3)
+//	SPAM_MAX_LINKS             most links a post may have (10)
+//	SPAM_LINK_DENSITY          links per word that hold a post with 3 links or more (0.2)
+//	SPAM_NEW_ACCOUNT_HOURS     account age below which posting links holds the post (24)
+func Default() Pipeline {
+	defaultOnce.Do(func() {
+		var terms []string
+		for _, term := range strings.Split(util.Getenv("SPAM_BLOCKLIST", ""), ",") {
+			if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		defaultPipeline = Pipeline{
+			Blocklist{Terms: terms},
+			Links{
+				Max:     util.Getenv("SPAM_MAX_LINKS", 10),
+				Density: util.Getenv("SPAM_LINK_DENSITY", 0.2),
+			},
+			NewAccount{Age: time.Duration(util.Getenv("SPAM_NEW_ACCOUNT_HOURS", 24)) * time.Hour},
+			Duplicate{
+				Distance: util.Getenv("SPAM_DUPLICATE_DISTANCE", 12),
+				RejectAt: util.Getenv("SPAM_DUPLICATE_REJECT", 3),
+				Recent:   20,
+				Within:   7 * 24 * time.Hour,
+				MinWords: 8,
+			},
+		}
+	})
+	return defaultPipeline
+}
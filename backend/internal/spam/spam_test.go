@@ -0,0 +1,208 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0b1011, 0b1011, 0},
+		{0b1011, 0b0011, 1},
+		{0, ^uint64(0), 64},
+		{0xF0F0, 0x0F0F, 16},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%b, %b) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSimhash(t *testing.T) {
+	const threshold = 12 // the default SPAM_DUPLICATE_DISTANCE
+	original := "Buy cheap followers for your account today, fast delivery and the best prices on the whole internet guaranteed"
+	tests := []struct {
+		name string
+		text string
+		near bool
+	}{
+		{"identical", original, true},
+		{"case and punctuation", strings.ToUpper(original) + "!!!", true},
+		{"one word changed", strings.Replace(original, "today", "now", 1), true},
+		{"distinct", "The migration to Postgres went fine, but the connection pool needs a larger limit under load", false},
+	}
+	hash := Simhash(Words(original))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := Distance(hash, Simhash(Words(tt.text)))
+			if near := distance <= threshold; near != tt.near {
+				t.Errorf("distance %d, near duplicate = %v, want %v", distance, near, tt.near)
+			}
+		})
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	check := Blocklist{Terms: []string{"casino", "spam.example"}}
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"Nothing to see here", ""},
+		{"Win big at the CASINO tonight", Reject},
+		{"see https://spam.example/offer", Reject},
+	}
+	for _, tt := range tests {
+		if got := check.Check(nil, Post{Body: tt.body}).Verdict; got != tt.want {
+			t.Errorf("Check(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	check := Links{Max: 4, Density: 0.2}
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no links", "just words here", ""},
+		{"two links", "see https://a.example and https://b.example for the details", ""},
+		{"dense links", "https://a.example https://b.example www.c.example look", Hold},
+		{"sparse links", "https://a.example https://b.example https://c.example " + strings.Repeat("word ", 20), ""},
+		{"too many links", "https://a.example https://b.example https://c.example https://d.example https://e.example", Reject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check.Check(nil, Post{Body: tt.body}).Verdict; got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAccount(t *testing.T) {
+	check := NewAccount{Age: 24 * time.Hour}
+	link := "read https://a.example"
+	tests := []struct {
+		name    string
+		created time.Time
+		body    string
+		want    string
+	}{
+		{"new account with a link", time.Now().Add(-time.Hour), link, Hold},
+		{"new account without links", time.Now().Add(-time.Hour), "hello there", ""},
+		{"old account with a link", time.Now().Add(-48 * time.Hour), link, ""},
+		{"unknown age", time.Time{}, link, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check.Check(nil, Post{UserCreatedAt: tt.created, Body: tt.body}).Verdict; got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// verdict is a check that always returns the same verdict and counts its runs
+type verdict struct {
+	name    string
+	verdict string
+	runs    *int
+}
+
+func (v verdict) Name() string { return v.name }
+
+func (v verdict) Check(db *gorm.DB, post Post) Result {
+	*v.runs++
+	return Result{Verdict: v.verdict, Reason: v.name}
+}
+
+func TestPipelineRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []string
+		want     string
+		found    []string // checks in the results
+		runs     int      // checks that ran
+	}{
+		{"all allow", []string{Allow, "", Allow}, Allow, nil, 3},
+		{"hold beats allow", []string{Allow, Hold, Allow}, Hold, []string{"check1"}, 3},
+		{"reject beats hold", []string{Hold, Reject}, Reject, []string{"check0", "check1"}, 2},
+		{"reject short-circuits", []string{Reject, Hold, Allow}, Reject, []string{"check0"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			var pipeline Pipeline
+			for i, v := range tt.verdicts {
+				pipeline = append(pipeline, verdict{name: "check" + string(rune('0'+i)), verdict: v, runs: &runs})
+			}
+			got, results := pipeline.Run(nil, Post{Body: "text"})
+			if got != tt.want {
+				t.Errorf("verdict = %q, want %q", got, tt.want)
+			}
+			if runs != tt.runs {
+				t.Errorf("%d checks ran, want %d", runs, tt.runs)
+			}
+			var found []string
+			for _, r := range results {
+				found = append(found, r.Check)
+			}
+			if strings.Join(found, ",") != strings.Join(tt.found, ",") {
+				t.Errorf("results of %v, want %v", found, tt.found)
+			}
+		})
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE threads (user_id TEXT, title TEXT, body TEXT, created_at DATETIME)",
+		"CREATE TABLE comments (user_id TEXT, content TEXT, created_at DATETIME)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := "Buy cheap followers for your account today, fast delivery and the best prices guaranteed"
+	now := time.Now()
+	db.Exec("INSERT INTO threads VALUES (?, ?, ?, ?)", "u1", "Followers", text, now.Add(-time.Hour))
+	db.Exec("INSERT INTO comments VALUES (?, ?, ?)", "u2", text, now.Add(-time.Hour))
+	db.Exec("INSERT INTO comments VALUES (?, ?, ?)", "u2", text, now.Add(-2*time.Hour))
+	db.Exec("INSERT INTO comments VALUES (?, ?, ?)", "u3", text, now.Add(-30*24*time.Hour))
+
+	check := Duplicate{Distance: 12, RejectAt: 2, Recent: 20, Within: 7 * 24 * time.Hour, MinWords: 8}
+	tests := []struct {
+		name string
+		post Post
+		want string
+	}{
+		{"one recent duplicate", Post{UserID: "u1", Body: text}, Hold},
+		{"two recent duplicates", Post{UserID: "u2", Body: text}, Reject},
+		{"duplicate too old", Post{UserID: "u3", Body: text}, ""},
+		{"other author", Post{UserID: "u4", Body: text}, ""},
+		{"distinct text", Post{UserID: "u2", Body: "The connection pool of the Postgres database needs a larger limit under load"}, ""},
+		{"too short", Post{UserID: "u2", Body: "Buy cheap followers"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check.Check(db, tt.post).Verdict; got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event types pushed to clients
+const (
+	ThreadCreated  = "thread.created"
+	ThreadDeleted  = "thread.deleted"
+	ThreadVoted    = "thread.vote"
+	CommentCreated = "comment.created"
+	CommentDeleted = "comment.deleted"
+	CommentVoted   = "comment.vote"
+)
+
+// Event is one forum change. ThreadID and Category are used to scope
+// subscriptions, Data is the JSON payload sent to clients.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	ThreadID string    `json:"thread_id,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Data     any       `json:"data"`
+	Time     time.Time `json:"time"`
+}
+
+// Scope limits a subscription to one thread and/or one category, empty
+// fields match everything
+type Scope struct {
+	ThreadID string
+	Category string
+}
+
+func (s Scope) matches(e Event) bool {
+	if s.ThreadID != "" && s.ThreadID != e.ThreadID {
+		return false
+	}
+	if s.Category != "" && s.Category != e.Category {
+		return false
+	}
+	return true
+}
+
+// Subscription receives the events of its scope on C. C is closed when the
+// subscriber is too slow to keep up or after Unsubscribe.
+type Subscription struct {
+	C     chan Event
+	scope Scope
+}
+
+// Hub fans events out to subscribers and keeps the most recent ones so
+// reconnecting clients can catch up (SSE Last-Event-ID)
+type Hub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	subs    map[*Subscription]struct{}
+	history []Event
+	size    int
+}
+
+// NewHub creates a hub remembering the last historySize events
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		subs: map[*Subscription]struct{}{},
+		size: historySize,
+	}
+}
+
+// Default is the hub the HTTP handlers publish to
+var Default = NewHub(256)
+
+// Publish sends an event to every matching subscriber of the default hub
+func Publish(eventType, threadID, category string, data any) {
+	Default.Publish(Event{Type: eventType, ThreadID: threadID, Category: category, Data: data})
+}
+
+// PublishForThread is Publish for callers that do not have the category of
+// the thread at hand, it is looked up
+func PublishForThread(db *gorm.DB, eventType, threadID string, data any) {
+	var categories []string
+	db.Table("threads").Where("id = ?", threadID).Limit(1).Pluck("category", &categories)
+	category := ""
+	if len(categories) > 0 {
+		category = categories[0]
+	}
+	Publish(eventType, threadID, category, data)
+}
+
+// Publish assigns the event an id and delivers it without blocking, a
+// subscriber whose buffer is full is dropped
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	e.ID = h.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	h.history = append(h.history, e)
+	if len(h.history) > h.size {
+		h.history = h.history[len(h.history)-h.size:]
+	}
+
+	for sub := range h.subs {
+		if !sub.scope.matches(e) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// Subscribe registers a subscriber. Events after lastID that are still in
+// the history are queued first, pass 0 to only get new events.
+func (h *Hub) Subscribe(scope Scope, lastID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{C: make(chan Event, 64), scope: scope}
+	if lastID > 0 {
+		for _, e := range h.history {
+			if e.ID > lastID && scope.matches(e) {
+				select {
+				case sub.C <- e:
+				default:
+				}
+			}
+		}
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.C)
+	}
+}
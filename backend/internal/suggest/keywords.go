@@ -0,0 +1,185 @@
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stopwords are left out of keywords, English and Indonesian like the posts
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a about after all also am an and any are as at be because been but by can could did do does
+		for from get got had has have how i if in into is it its just like me more my no not of on
+		one or our out so some than that the their them then there these they this to up us was we
+		were what when where which who why will with would you your anyone please thanks hi hello
+		ada adalah agar akan aku anda apa atau bagaimana bahwa bisa dalam dan dari dengan di dia
+		ini itu jadi juga kalau kami kamu karena ke kenapa ketika lagi mau mereka pada saja saya
+		sudah tapi tentang tidak untuk yang ya`) {
+		stopwords[w] = true
+	}
+}
+
+// words splits text into lower case words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Keywords are the n most frequent words of text that say something, the
+// title counting three times as much as the body
+func Keywords(title, body string, n int) []string {
+	counts := map[string]int{}
+	var order []string
+	add := func(text string, weight int) {
+		for _, w := range words(text) {
+			if stopwords[w] || utf8.RuneCountInString(w) < 3 {
+				continue
+			}
+			if counts[w] == 0 {
+				order = append(order, w)
+			}
+			counts[w] += weight
+		}
+	}
+	add(title, 3)
+	add(body, 1)
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	if len(order) > n {
+		order = order[:n]
+	}
+	return order
+}
+
+// Similarity is the share of keywords two texts have in common, from 0 to 1
+func Similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range a {
+		set[w] = true
+	}
+	shared := 0
+	for _, w := range b {
+		if set[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Heuristics suggests the categories whose name, description and recent
+// thread titles share the most keywords with the draft, the draft's title
+// tidied up (or its first sentence) and the candidates sharing the most
+// keywords with it
+func Heuristics(draft Draft, categories []Category, candidates []Thread) Suggestion {
+	keywords := Keywords(draft.Title, draft.Body, 30)
+	weight := map[string]float64{}
+	for i, k := range keywords {
+		weight[k] = 1 / float64(i+1) // the most frequent keywords count more
+	}
+
+	scores := []CategoryScore{}
+	total := 0.0
+	for _, category := range categories {
+		score := 0.0
+		seen := map[string]bool{}
+		match := func(text string, factor float64) {
+			for _, w := range words(text) {
+				if !seen[w] {
+					seen[w] = true
+					score += factor * weight[w]
+				}
+			}
+		}
+		// What the category says it is about counts more than how it is used
+		match(category.Slug+" "+category.Name+" "+category.Description, 2)
+		match(strings.Join(category.Titles, " "), 1)
+		if score > 0 {
+			scores = append(scores, CategoryScore{Slug: category.Slug, Name: category.Name, Confidence: score})
+			total += score
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Confidence > scores[j].Confidence })
+	if len(scores) > MaxCategories {
+		scores = scores[:MaxCategories]
+	}
+	for i := range scores {
+		scores[i].Confidence = round(scores[i].Confidence / total)
+	}
+
+	title := draft.Title
+	if strings.TrimSpace(title) == "" {
+		title = firstSentence(draft.Body)
+	}
+	return Suggestion{
+		Source:     SourceKeywords,
+		Title:      CleanTitle(title),
+		Categories: scores,
+		Duplicates: duplicates(keywords, candidates, 0.25),
+	}
+}
+
+// duplicates are the candidates sharing at least threshold of their
+// keywords with the draft's
+func duplicates(keywords []string, candidates []Thread, threshold float64) []Duplicate {
+	found := []Duplicate{}
+	for _, t := range candidates {
+		similarity := Similarity(keywords, Keywords(t.Title, t.Body, 30))
+		if similarity >= threshold {
+			found = append(found, Duplicate{ID: t.ID, Title: t.Title, Similarity: round(similarity)})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Similarity > found[j].Similarity })
+	if len(found) > MaxDuplicates {
+		found = found[:MaxDuplicates]
+	}
+	return found
+}
+
+// CleanTitle collapses whitespace, drops trailing punctuation runs but a
+// single question mark, capitalizes the first letter and keeps it under
+// MaxTitle runes, cut at a word
+func CleanTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	question := strings.HasSuffix(strings.TrimRight(title, "!.,;: "), "?")
+	title = strings.TrimRight(title, "!?.,;: ")
+	if runes := []rune(title); len(runes) > MaxTitle {
+		title = string(runes[:MaxTitle])
+		if i := strings.LastIndex(title, " "); i > MaxTitle/2 {
+			title = title[:i]
+		}
+		title = strings.TrimRight(title, "!?.,;: ")
+	}
+	if title == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(title)
+	title = string(unicode.ToUpper(first)) + title[size:]
+	if question {
+		title += "?"
+	}
+	return title
+}
+
+// firstSentence is the first sentence or line of text
+func firstSentence(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexAny(text, ".?!\n"); i >= 0 {
+		end := i
+		if text[i] == '?' {
+			end++
+		}
+		return text[:end]
+	}
+	return text
+}
+
+func round(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
@@ -0,0 +1,140 @@
+package suggest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const prompt = `You help users of a discussion forum post their thread in the right place.
+Given the draft below:
+1. Pick the categories that fit it best, at most %d, only from this list (slug: name - description):
+%s
+2. Write a clear, specific title for it, at most %d characters, in the language of the draft.
+3. Tell which of these existing threads ask the same thing, by number, if any:
+%s
+Answer with JSON only, in this form:
+{"categories":[{"slug":"<slug>","confidence":<0 to 1>}],"title":"<title>","duplicates":[<numbers>]}
+
+Draft title: %s
+Draft body:
+"""
+%s
+"""`
+
+// maxDraft is how much of the draft body is sent to the model, in runes
+const maxDraft = 6000
+
+// answer is the JSON the model is asked for
+type answer struct {
+	Categories []struct {
+		Slug       string  `json:"slug"`
+		Confidence float64 `json:"confidence"`
+	} `json:"categories"`
+	Title      string `json:"title"`
+	Duplicates []int  `json:"duplicates"`
+}
+
+// WithLLM has model write the suggestion. The answer is validated: only
+// known categories and listed candidates are kept, and an answer without
+// any valid category is an error.
+func WithLLM(ctx context.Context, model llms.Model, draft Draft, categories []Category, candidates []Thread) (Suggestion, error) {
+	if len(categories) == 0 {
+		return Suggestion{}, errors.New("no categories to suggest")
+	}
+	var list strings.Builder
+	for _, c := range categories {
+		about := strings.Join(strings.Fields(c.Description), " ")
+		if about == "" && len(c.Titles) > 0 {
+			about = "threads like \"" + strings.Join(c.Titles[:min(len(c.Titles), 3)], "\", \"") + "\""
+		}
+		fmt.Fprintf(&list, "- %s: %s - %s\n", c.Slug, c.Name, about)
+	}
+	var threads strings.Builder
+	for i, t := range candidates {
+		fmt.Fprintf(&threads, "%d. %s\n", i+1, t.Title)
+	}
+	if len(candidates) == 0 {
+		threads.WriteString("(none)\n")
+	}
+	body := draft.Body
+	if runes := []rune(body); len(runes) > maxDraft {
+		body = string(runes[:maxDraft])
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	text, err := llms.GenerateFromSinglePrompt(ctx, model,
+		fmt.Sprintf(prompt, MaxCategories, list.String(), MaxTitle, threads.String(), draft.Title, body),
+		llms.WithTemperature(0),
+		llms.WithJSONMode(),
+	)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	return parse(text, draft, categories, candidates)
+}
+
+// parse validates the model's answer against the categories and candidates
+// it was given
+func parse(text string, draft Draft, categories []Category, candidates []Thread) (Suggestion, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return Suggestion{}, fmt.Errorf("no JSON object in the model answer %q", text)
+	}
+	var a answer
+	if err := json.Unmarshal([]byte(text[start:end+1]), &a); err != nil {
+		return Suggestion{}, fmt.Errorf("invalid model answer: %w", err)
+	}
+
+	bySlug := map[string]Category{}
+	for _, c := range categories {
+		bySlug[c.Slug] = c
+	}
+	s := Suggestion{Source: SourceLLM, Categories: []CategoryScore{}, Duplicates: []Duplicate{}}
+	for _, suggested := range a.Categories {
+		c, ok := bySlug[strings.ToLower(strings.TrimSpace(suggested.Slug))]
+		if !ok || len(s.Categories) == MaxCategories {
+			continue
+		}
+		delete(bySlug, c.Slug) // once each
+		s.Categories = append(s.Categories, CategoryScore{
+			Slug:       c.Slug,
+			Name:       c.Name,
+			Confidence: round(min(max(suggested.Confidence, 0), 1)),
+		})
+	}
+	if len(s.Categories) == 0 {
+		return Suggestion{}, fmt.Errorf("the model suggested no known category: %q", text)
+	}
+	sort.SliceStable(s.Categories, func(i, j int) bool { return s.Categories[i].Confidence > s.Categories[j].Confidence })
+
+	if s.Title = CleanTitle(a.Title); s.Title == "" {
+		title := draft.Title
+		if strings.TrimSpace(title) == "" {
+			title = firstSentence(draft.Body)
+		}
+		s.Title = CleanTitle(title)
+	}
+
+	keywords := Keywords(draft.Title, draft.Body, 30)
+	seen := map[int]bool{}
+	for _, n := range a.Duplicates {
+		if n < 1 || n > len(candidates) || seen[n] || len(s.Duplicates) == MaxDuplicates {
+			continue
+		}
+		seen[n] = true
+		t := candidates[n-1]
+		s.Duplicates = append(s.Duplicates, Duplicate{
+			ID:         t.ID,
+			Title:      t.Title,
+			Similarity: round(Similarity(keywords, Keywords(t.Title, t.Body, 30))),
+		})
+	}
+	return s, nil
+}
@@ -0,0 +1,91 @@
+// Package suggest helps users compose a thread: it suggests categories from
+// the existing set, a clearer title and threads that may already ask the
+// same. The LLM of LLM_PROVIDER writes the suggestions when there is one,
+// keyword heuristics otherwise.
+package suggest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"microblog/backend/pkg/llmClient"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sources of a Suggestion
+const (
+	SourceLLM      = "llm"
+	SourceKeywords = "keywords"
+)
+
+// Draft is the thread being composed
+type Draft struct {
+	Title string
+	Body  string
+}
+
+// Category is one of the categories a thread can be posted in
+type Category struct {
+	Slug        string
+	Name        string
+	Description string
+	Titles      []string // of recent threads in it, what it is used for
+}
+
+// Thread is an existing thread that may be a duplicate of the draft
+type Thread struct {
+	ID    string
+	Title string
+	Body  string
+}
+
+// CategoryScore is a suggested category
+type CategoryScore struct {
+	Slug       string  `json:"slug"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"` // from 0 to 1
+}
+
+// Duplicate is an existing thread the draft may repeat
+type Duplicate struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Similarity float64 `json:"similarity"` // from 0 to 1
+}
+
+// Suggestion is what is suggested for a draft
+type Suggestion struct {
+	Source     string          `json:"source"` // llm | keywords
+	Title      string          `json:"title"`
+	Categories []CategoryScore `json:"categories"` // best first
+	Duplicates []Duplicate     `json:"duplicates"` // most similar first
+}
+
+// Limits of a Suggestion
+const (
+	MaxCategories = 3
+	MaxDuplicates = 5
+	MaxTitle      = 120 // runes
+)
+
+// Timeout bounds asking the LLM, the heuristics answer after it
+var Timeout = 15 * time.Second
+
+// Suggest suggests categories, a title and duplicates among candidates for
+// draft. It asks the configured LLM and falls back to Heuristics when there
+// is none or its answer is unusable.
+func Suggest(ctx context.Context, draft Draft, categories []Category, candidates []Thread) Suggestion {
+	model, _, err := llmClient.Model()
+	if err == nil {
+		var s Suggestion
+		if s, err = WithLLM(ctx, model, draft, categories, candidates); err == nil {
+			return s
+		}
+	}
+	if !errors.Is(err, llmClient.ErrNoProvider) {
+		logrus.Println("suggest: falling back to keywords:", err)
+	}
+	return Heuristics(draft, categories, candidates)
+}
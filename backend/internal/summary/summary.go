@@ -0,0 +1,119 @@
+// Package summary writes the TL;DR of a thread with the configured LLM. The
+// prompt holds the thread and as many of its top comments as fit the token
+// budget of the model's context.
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"microblog/backend/pkg/llmClient"
+	"microblog/backend/pkg/util"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Thread is what a summary is written from
+type Thread struct {
+	Title    string
+	Body     string
+	Category string
+	Comments []Comment // best first
+}
+
+// Comment is a comment of the thread
+type Comment struct {
+	Author string
+	Score  int
+	Body   string
+}
+
+// Timeout bounds writing a summary
+var Timeout = 90 * time.Second
+
+const instructions = `Summarize the forum discussion below for someone who has not read it.
+Write a TL;DR of at most 6 short bullet points in the language of the thread: the question or topic,
+the main answers and positions, points of agreement or disagreement, and any conclusion reached.
+Do not invent anything that is not in the discussion. Answer with the bullet points only.
+
+`
+
+// Budget is the most tokens a prompt may take, SUMMARY_MAX_TOKENS (6000),
+// leaving the rest of the model's context for the summary
+func Budget() int {
+	return util.Getenv("SUMMARY_MAX_TOKENS", 6000)
+}
+
+// RefreshAfter is how many new comments make a summary stale,
+// SUMMARY_REFRESH_COMMENTS (10)
+func RefreshAfter() int {
+	return util.Getenv("SUMMARY_REFRESH_COMMENTS", 10)
+}
+
+// Prompt builds the prompt of thread within budget tokens, counted with
+// llmClient.CountTokens. The thread body takes at most half of the budget,
+// comments are added best first until the next one does not fit. It returns
+// how many comments made it and the tokens of the prompt.
+func Prompt(thread Thread, budget int) (string, int, int) {
+	var b strings.Builder
+	b.WriteString(instructions)
+	fmt.Fprintf(&b, "Thread: %s\nCategory: %s\n\n", thread.Title, thread.Category)
+	tokens := llmClient.CountTokens(b.String())
+
+	body := truncate(thread.Body, (budget-tokens)/2)
+	b.WriteString(body)
+	b.WriteString("\n\nComments, most upvoted first:\n")
+	tokens = llmClient.CountTokens(b.String())
+
+	included := 0
+	for _, comment := range thread.Comments {
+		entry := fmt.Sprintf("\n- %s (score %d): %s\n", comment.Author, comment.Score, strings.TrimSpace(comment.Body))
+		n := llmClient.CountTokens(entry)
+		if tokens+n > budget {
+			break
+		}
+		b.WriteString(entry)
+		tokens += n
+		included++
+	}
+	return b.String(), included, tokens
+}
+
+// truncate cuts text to about budget tokens
+func truncate(text string, budget int) string {
+	if budget <= 0 {
+		return ""
+	}
+	n := llmClient.CountTokens(text)
+	if n <= budget {
+		return text
+	}
+	// Tokens are not evenly spread over runes, shrink until it fits
+	runes := []rune(text)
+	for n > budget && len(runes) > 0 {
+		runes = runes[:len(runes)*budget/n*9/10]
+		n = llmClient.CountTokens(string(runes))
+	}
+	return string(runes) + " [...]"
+}
+
+// Summarize has model write the summary of prompt
+func Summarize(ctx context.Context, model llms.Model, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	answer, err := llms.GenerateFromSinglePrompt(ctx, model, prompt,
+		llms.WithTemperature(0.2),
+		llms.WithMaxTokens(util.Getenv("SUMMARY_OUTPUT_TOKENS", 400)),
+	)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", errors.New("the model wrote an empty summary")
+	}
+	return answer, nil
+}
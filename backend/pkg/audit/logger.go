@@ -8,7 +8,7 @@ import (
 func Log(
 	c *gin.Context,
 	db *gorm.DB,
-	userID uint,
+	userID string,
 	entry *Entry,
 ) {
 	if c == nil || db == nil || entry == nil {
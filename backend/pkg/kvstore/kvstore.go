@@ -235,3 +235,46 @@ func GetKeyTTL(key string) (time.Duration, error) {
 	}
 	return 0, fmt.Errorf("key not found")
 }
+
+// IncrKey atomically adds 1 to a counter key and returns the new count. A
+// new counter expires after ttl, incrementing does not extend it.
+func IncrKey(key string, ttl time.Duration) (int64, error) {
+	if redisUp.Load() {
+		ctx := context.Background()
+		count, err := RDB.Incr(ctx, key).Result()
+		if err == nil {
+			if count == 1 {
+				RDB.Expire(ctx, key, ttl)
+			}
+			return count, nil
+		}
+		redisUp.Store(false)
+	}
+
+	shard := getShard(key)
+	for {
+		expiration := time.Now().Add(ttl)
+		val, loaded := shard.LoadOrStore(key, valueWithTTL{value: "1", ttl: expiration})
+		if !loaded {
+			// Only this counter expires, not one started after it under the same key
+			time.AfterFunc(ttl, func() {
+				for {
+					val, ok := shard.Load(key)
+					if !ok || !val.(valueWithTTL).ttl.Equal(expiration) || shard.CompareAndDelete(key, val) {
+						return
+					}
+				}
+			})
+			return 1, nil
+		}
+		v := val.(valueWithTTL)
+		if !time.Now().Before(v.ttl) {
+			shard.CompareAndDelete(key, v)
+			continue
+		}
+		count, _ := strconv.ParseInt(v.value, 10, 64)
+		if shard.CompareAndSwap(key, v, valueWithTTL{value: strconv.FormatInt(count+1, 10), ttl: v.ttl}) {
+			return count + 1, nil
+		}
+	}
+}
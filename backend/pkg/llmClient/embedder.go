@@ -0,0 +1,131 @@
+package llmClient
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+var (
+	embedderMu     sync.Mutex
+	embedderLoaded bool
+	embedder       embeddings.Embedder
+	embedderName   string
+	embedderErr    error
+)
+
+// Embedder computes embeddings with EMBEDDING_PROVIDER, LLM_PROVIDER when
+// unset: openai (EMBEDDING_MODEL, text-embedding-3-small), ollama
+// (EMBEDDING_MODEL at OLLLAMA_API_URL, nomic-embed-text) or fake, a
+// deterministic FakeEmbedder. It is returned with the name of its model,
+// vectors of different models cannot be compared.
+func Embedder() (embeddings.Embedder, string, error) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	if !embedderLoaded {
+		provider := strings.ToLower(strings.TrimSpace(os.Getenv("EMBEDDING_PROVIDER")))
+		if provider == "" {
+			provider = strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER")))
+		}
+		embedder, embedderName, embedderErr = newEmbedder(provider)
+		embedderLoaded = true
+	}
+	return embedder, embedderName, embedderErr
+}
+
+// SetEmbedder replaces the configured embedder, name being its model
+func SetEmbedder(e embeddings.Embedder, name string) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	embedder, embedderName, embedderErr, embedderLoaded = e, name, nil, true
+}
+
+func newEmbedder(provider string) (embeddings.Embedder, string, error) {
+	model := os.Getenv("EMBEDDING_MODEL")
+	switch provider {
+	case ProviderOpenAI:
+		if model == "" {
+			model = "text-embedding-3-small"
+		}
+		client, err := openai.New(openai.WithEmbeddingModel(model))
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := embeddings.NewEmbedder(client)
+		return e, provider + "/" + model, err
+	case ProviderOllama:
+		if model == "" {
+			model = "nomic-embed-text"
+		}
+		client, err := ollama.New(
+			ollama.WithModel(model),
+			ollama.WithServerURL(os.Getenv("OLLLAMA_API_URL")),
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := embeddings.NewEmbedder(client)
+		return e, provider + "/" + model, err
+	case ProviderFake:
+		return FakeEmbedder{}, "fake/hash-256", nil
+	case "":
+		return nil, "", ErrNoProvider
+	}
+	return nil, "", errors.New("unknown embedding provider " + provider)
+}
+
+// FakeEmbedder hashes the words of a text into a vector without any network,
+// texts sharing words get similar vectors. The same text always gets the
+// same vector.
+type FakeEmbedder struct {
+	Dimensions int // 256 when 0
+}
+
+func (f FakeEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = f.EmbedQuery(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (f FakeEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	dims := f.Dimensions
+	if dims <= 0 {
+		dims = 256
+	}
+	vector := make([]float32, dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+		if sum&(1<<63) != 0 {
+			vector[sum%uint64(dims)]--
+		} else {
+			vector[sum%uint64(dims)]++
+		}
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] = float32(float64(vector[i]) / norm)
+		}
+	}
+	return vector, nil
+}
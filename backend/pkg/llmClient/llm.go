@@ -3,6 +3,7 @@ package llmClient
 import (
 	"log"
 	"os"
+	"sync"
 
 	"github.com/pkoukk/tiktoken-go"
 	"github.com/sirupsen/logrus"
@@ -40,17 +41,30 @@ func InitOLLAMA() *ollama.LLM {
 	return llm
 }
 
+var (
+	encoderOnce sync.Once
+	encoder     *tiktoken.Tiktoken
+)
+
 // CountTokens counts the number of tokens in a given string for a specific model.
+// The encoder is loaded once, when it cannot be (tiktoken downloads it on
+// first use) tokens are estimated at four bytes each.
 func CountTokens(text string) int {
-	enc, err := tiktoken.EncodingForModel(LLM_MODEL)
-	if err != nil {
-		log.Printf("fallback to cl100k_base encoder: %v", err)
-		enc, err = tiktoken.GetEncoding("cl100k_base")
+	encoderOnce.Do(func() {
+		enc, err := tiktoken.EncodingForModel(LLM_MODEL)
 		if err != nil {
-			log.Fatalf("failed to get encoder: %v", err)
+			log.Printf("fallback to cl100k_base encoder: %v", err)
+			enc, err = tiktoken.GetEncoding("cl100k_base")
+			if err != nil {
+				logrus.Errorf("failed to get encoder, estimating tokens: %v", err)
+			}
 		}
+		encoder = enc
+	})
+	if encoder == nil {
+		return (len(text) + 3) / 4
 	}
 
-	tokens := enc.Encode(text, nil, nil)
+	tokens := encoder.Encode(text, nil, nil)
 	return len(tokens)
 }
@@ -0,0 +1,122 @@
+package llmClient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Providers of Model, picked with LLM_PROVIDER
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
+
+// ErrNoProvider is returned when LLM_PROVIDER is not set, the features
+// built on the LLM then use their fallbacks
+var ErrNoProvider = errors.New("no LLM provider configured")
+
+var (
+	modelMu       sync.Mutex
+	modelLoaded   bool
+	model         llms.Model
+	modelProvider string
+	modelErr      error
+)
+
+// Model is the LLM of LLM_PROVIDER: openai (OPENAI_API_KEY, model
+// LLM_MODEL), ollama (OLLLAMA_MODEL at OLLLAMA_API_URL) or fake, which
+// answers every prompt with LLM_FAKE_RESPONSE without any network. It is
+// created on first use and returned with its provider name.
+func Model() (llms.Model, string, error) {
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	if !modelLoaded {
+		modelProvider = strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER")))
+		model, modelErr = newModel(modelProvider)
+		modelLoaded = true
+	}
+	return model, modelProvider, modelErr
+}
+
+// SetModel replaces the model of the configured provider, like with a Fake
+func SetModel(m llms.Model, provider string) {
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	model, modelProvider, modelErr, modelLoaded = m, provider, nil, true
+}
+
+func newModel(provider string) (llms.Model, error) {
+	switch provider {
+	case ProviderOpenAI:
+		llm, err := openai.New(openai.WithModel(LLM_MODEL))
+		if err != nil {
+			return nil, err
+		}
+		GPTLLM = llm
+		return llm, nil
+	case ProviderOllama:
+		llm, err := ollama.New(
+			ollama.WithModel(os.Getenv("OLLLAMA_MODEL")),
+			ollama.WithServerURL(os.Getenv("OLLLAMA_API_URL")),
+		)
+		if err != nil {
+			return nil, err
+		}
+		OLLAMA = llm
+		return llm, nil
+	case ProviderFake:
+		return Fake{Respond: func(string) (string, error) {
+			if response := os.Getenv("LLM_FAKE_RESPONSE"); response != "" {
+				return response, nil
+			}
+			return "", errors.New("LLM_FAKE_RESPONSE is not set")
+		}}, nil
+	case "":
+		return nil, ErrNoProvider
+	}
+	return nil, errors.New("unknown LLM provider " + provider)
+}
+
+// Generate sends a single prompt to the configured model
+func Generate(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	m, _, err := Model()
+	if err != nil {
+		return "", err
+	}
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// Fake is an offline llms.Model that answers with Respond, for running the
+// LLM features without a model server
+type Fake struct {
+	Respond func(prompt string) (string, error)
+}
+
+func (f Fake) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var prompt strings.Builder
+	for _, m := range messages {
+		for _, part := range m.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				prompt.WriteString(text.Text)
+				prompt.WriteString("\n")
+			}
+		}
+	}
+	response, err := f.Respond(prompt.String())
+	if err != nil {
+		return nil, err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: response}}}, nil
+}
+
+func (f Fake) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"bytes"
+
+	"microblog/backend/pkg/types"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/sirupsen/logrus"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// md renders CommonMark plus the GFM tables, strikethrough and autolinks.
+// Raw HTML in the source is dropped (goldmark only passes it through with
+// html.WithUnsafe), hard wraps keep single newlines as line breaks the way
+// users type them in a textarea.
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+	),
+)
+
+// policy is a second line of defense on the rendered HTML: user content
+// elements only, safe URL schemes, and rel="nofollow noopener" on links
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	p.AllowAttrs("align").OnElements("th", "td")
+	return p
+}()
+
+// Render converts Markdown to sanitized HTML
+func Render(source string) types.HTML {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		logrus.Errorf("markdown: %v", err)
+		return types.HTML(policy.Sanitize("<p>" + bluemonday.StrictPolicy().Sanitize(source) + "</p>"))
+	}
+	return types.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
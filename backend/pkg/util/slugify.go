@@ -0,0 +1,25 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify lowercases s and joins its letters and digits with single
+// hyphens, "  Web Design!" becomes "web-design"
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
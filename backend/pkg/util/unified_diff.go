@@ -0,0 +1,158 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines kept around each change
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// UnifiedDiff returns the line diff of a and b in unified format, the way
+// `diff -u` prints it, or "" when they are equal
+func UnifiedDiff(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	// Line numbers in a and b before each diff line
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.kind != '+' {
+			aPos[i+1]++
+		}
+		if l.kind != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk while the next change is close enough to share context
+		start := max(i-diffContext, 0)
+		last := i
+		for end := i; end < len(lines); end++ {
+			if lines[end].kind != ' ' {
+				last = end
+			} else if end-last > 2*diffContext {
+				break
+			}
+		}
+		end := min(last+diffContext+1, len(lines))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]),
+			hunkRange(bPos[start], bPos[end]-bPos[start]))
+		for _, l := range lines[start:end] {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// maxDiffCells bounds the LCS matrix of diffLines, about 8 MB
+const maxDiffCells = 1 << 20
+
+// diffLines aligns a and b on their longest common subsequence. The shared
+// head and tail are trimmed first, edits usually touch a few lines of a post.
+// When the changed middle is too large for the matrix it is shown as
+// removed and added as a whole.
+func diffLines(a, b []string) []diffLine {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+	ma, mb := a[head:len(a)-tail], b[head:len(b)-tail]
+
+	var lines []diffLine
+	for _, s := range a[:head] {
+		lines = append(lines, diffLine{' ', s})
+	}
+	if (len(ma)+1)*(len(mb)+1) > maxDiffCells {
+		for _, s := range ma {
+			lines = append(lines, diffLine{'-', s})
+		}
+		for _, s := range mb {
+			lines = append(lines, diffLine{'+', s})
+		}
+	} else {
+		lines = append(lines, alignLines(ma, mb)...)
+	}
+	for _, s := range a[len(a)-tail:] {
+		lines = append(lines, diffLine{' ', s})
+	}
+	return lines
+}
+
+// alignLines diffs ma and mb on their longest common subsequence
+func alignLines(ma, mb []string) []diffLine {
+	// lcs[i][j] is the LCS length of ma[i:] and mb[j:]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			lines = append(lines, diffLine{' ', ma[i]})
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', ma[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', mb[j]})
+			j++
+		}
+	}
+	return lines
+}